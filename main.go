@@ -2,9 +2,11 @@ package main
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"log"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+	"github.com/albert-zhong/go-coin/pkg/wallet"
 )
 
 func main() {
@@ -14,64 +16,60 @@ func main() {
 }
 
 func testGenesisBlock() error {
-	emptyChain := NewChain(nil)
-	node := NewNode(emptyChain)
+	chain, err := core.NewChain(nil)
+	if err != nil {
+		return err
+	}
+	node := core.NewNode(chain)
+	pool := mempool.New()
 
-	alicePriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	alice, err := wallet.NewWallet()
 	if err != nil {
 		return err
 	}
-	bobPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bob, err := wallet.NewWallet()
 	if err != nil {
 		return err
 	}
+	log.Printf("alice: %s", alice.Address())
+	log.Printf("bob:   %s", bob.Address())
 
-	// alice gives the mining reward to herself
-	coinbaseTransaction, err := NewTransaction(
-		&TransactionBody{
-			InputTransactionHashes: nil,
-			NextOwner:              &alicePriv.PublicKey,
-			InputValue:             MiningReward,
-			Change:                 0,
-			Fee:                    0,
-		},
-		nil,
-		true,
-	)
+	// Mine the genesis block: an empty mempool means the only
+	// transaction is the coinbase, paying the mining reward to alice's
+	// address.
+	genesis, err := node.Mine(pool, 0, alice.PubKeyHash())
 	if err != nil {
 		return err
 	}
-	// alice gives 5 coins to bob
-	transaction, err := NewTransaction(
-		&TransactionBody{
+	genesis.Print()
+
+	// alice gives 5 coins to bob, keeping the rest as change.
+	coinbase := genesis.Body.Transactions[0]
+	transaction, err := core.NewTransaction(
+		&core.TransactionBody{
 			InputTransactionHashes: [][]byte{
-				coinbaseTransaction.BodyHash,
+				coinbase.BodyHash,
 			},
-			NextOwner:  &bobPriv.PublicKey,
-			InputValue: 10,
+			NextOwner:  bob.PubKeyHash(),
+			InputValue: core.MiningReward,
 			Change:     5,
 			Fee:        0,
 		},
 		[]*ecdsa.PrivateKey{
-			alicePriv,
+			alice.PrivateKey,
 		},
 		false,
 	)
 	if err != nil {
 		return err
 	}
-
-	// create genesis block with the two transactions
-	blockBody := &BlockBody{
-		Version:  CurrentVersion,
-		Id:       0,
-		PrevHash: nil,
-		Transactions: []*Transaction{
-			coinbaseTransaction,
-			transaction,
-		},
+	if err := pool.Add(chain, transaction); err != nil {
+		return err
 	}
-	block, err := node.Mine(blockBody)
+
+	// Mine a second block that picks alice's payment up from the
+	// mempool.
+	block, err := node.Mine(pool, 1, alice.PubKeyHash())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Serialize and Deserialize convert chain objects to and from the flat
+// binary form persisted by pkg/storage. Only the static fields that
+// staticHash/hash don't already derive are written out; everything else
+// (BodyHash, bodyStaticHash, transaction hashes) is recomputed on load so
+// storage never has to trust a value it didn't verify itself.
+
+// Serialize encodes the block's body for storage. The nonce and time are
+// included since they aren't recoverable otherwise.
+func (b *Block) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeUint32(&buf, b.Body.Version); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, b.Body.Id); err != nil {
+		return nil, err
+	}
+	if err := writeBytes(&buf, b.Body.PrevHash); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, uint32(len(b.Body.Transactions))); err != nil {
+		return nil, err
+	}
+	for _, transaction := range b.Body.Transactions {
+		txBytes, err := transaction.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, txBytes); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte(b.Body.LeadingZeros)
+	if err := writeUint32(&buf, b.Body.Nonce); err != nil {
+		return nil, err
+	}
+	timeBytes, err := b.Body.Time.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBytes(&buf, timeBytes); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DeserializeBlock reconstructs a Block from bytes written by Serialize,
+// recomputing its hashes rather than trusting stored ones.
+func DeserializeBlock(data []byte) (*Block, error) {
+	r := bytes.NewReader(data)
+	version, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	prevHash, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	txCount, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]*Transaction, 0, safeCount(txCount, r))
+	for i := uint32(0); i < txCount; i++ {
+		txBytes, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		transaction, err := DeserializeTransaction(txBytes)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	leadingZeros, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	timeBytes, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	var blockTime time.Time
+	if err := blockTime.UnmarshalBinary(timeBytes); err != nil {
+		return nil, err
+	}
+
+	block, err := NewBlock(&BlockBody{
+		Version:      version,
+		Id:           id,
+		PrevHash:     prevHash,
+		Transactions: transactions,
+		LeadingZeros: leadingZeros,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := block.SetFields(nonce, blockTime); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// Serialize encodes the transaction for storage. NextOwner is already a
+// pubKeyHash, so it's written as-is; each OwnerSignature's public key is
+// written as a compressed P256 point, the same form used by Block.Print
+// for NextOwner before it became a hash.
+func (t *Transaction) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeUint32(&buf, uint32(len(t.Body.InputTransactionHashes))); err != nil {
+		return nil, err
+	}
+	for _, inputTransactionHash := range t.Body.InputTransactionHashes {
+		if err := writeBytes(&buf, inputTransactionHash); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeBytes(&buf, t.Body.NextOwner); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, t.Body.InputValue); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, t.Body.Change); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, t.Body.Fee); err != nil {
+		return nil, err
+	}
+	if err := writeUint32(&buf, uint32(len(t.OwnerSignatures))); err != nil {
+		return nil, err
+	}
+	for _, ownerSignature := range t.OwnerSignatures {
+		publicKeyBytes := elliptic.MarshalCompressed(ownerSignature.PublicKey.Curve, ownerSignature.PublicKey.X, ownerSignature.PublicKey.Y)
+		if err := writeBytes(&buf, publicKeyBytes); err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, ownerSignature.R.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, ownerSignature.S.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	isCoinbase := byte(0)
+	if t.isCoinbase {
+		isCoinbase = 1
+	}
+	buf.WriteByte(isCoinbase)
+	return buf.Bytes(), nil
+}
+
+// DeserializeTransaction reconstructs a Transaction from bytes written by
+// Serialize, recomputing BodyHash rather than trusting a stored one.
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+	r := bytes.NewReader(data)
+	hashCount, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	inputTransactionHashes := make([][]byte, 0, safeCount(hashCount, r))
+	for i := uint32(0); i < hashCount; i++ {
+		h, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		inputTransactionHashes = append(inputTransactionHashes, h)
+	}
+	nextOwner, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(nextOwner) != ripemd160.Size {
+		return nil, fmt.Errorf("deserialize transaction: next owner %x is not a %d-byte pubKeyHash", nextOwner, ripemd160.Size)
+	}
+
+	inputValue, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	change, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	sigCount, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	ownerSignatures := make([]OwnerSignature, 0, safeCount(sigCount, r))
+	for i := uint32(0); i < sigCount; i++ {
+		publicKeyBytes, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKeyBytes)
+		if x == nil {
+			return nil, fmt.Errorf("deserialize transaction: invalid owner signature public key")
+		}
+		rBytes, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		sBytes, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		ownerSignatures = append(ownerSignatures, OwnerSignature{
+			PublicKey: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+			R:         new(big.Int).SetBytes(rBytes),
+			S:         new(big.Int).SetBytes(sBytes),
+		})
+	}
+	isCoinbaseByte, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	body := &TransactionBody{
+		InputTransactionHashes: inputTransactionHashes,
+		NextOwner:              nextOwner,
+		InputValue:             inputValue,
+		Change:                 change,
+		Fee:                    fee,
+	}
+	bodyHash, err := body.hash()
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{
+		Body:            body,
+		BodyHash:        bodyHash,
+		OwnerSignatures: ownerSignatures,
+		isCoinbase:      isCoinbaseByte == 1,
+	}, nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	return binary.Write(w, binary.LittleEndian, v)
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var v uint32
+	if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func writeBytes(w io.Writer, b []byte) error {
+	if err := writeUint32(w, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// safeCount clamps count, a length-prefixed element count read straight off
+// the wire, to the most elements r could still possibly hold. Without this,
+// a corrupt or malicious payload can claim a count like 0xFFFFFFF0 in an
+// otherwise tiny buffer and turn it straight into slice-preallocation
+// capacity, which crashes the process with an unrecoverable out-of-memory
+// fault long before the loop reading each element ever hits EOF. Every
+// element is at least 4 bytes (its own length prefix), so r.Len()/4 is a
+// safe upper bound.
+func safeCount(count uint32, r *bytes.Reader) int {
+	if maxPossible := r.Len() / 4; count > uint32(maxPossible) {
+		return maxPossible
+	}
+	return int(count)
+}
+
+// maxPayloadSize bounds any single length-prefixed field readBytes reads
+// (PrevHash, NextOwner, a signature's R/S bytes, the marshaled time, ...).
+// Without it, a corrupt or malicious rawtx/rawblock payload can claim an
+// arbitrary field length and make readBytes allocate gigabytes via
+// make([]byte, n) before io.ReadFull ever gets a chance to fail on the
+// short read, crashing the process with an unrecoverable out-of-memory
+// fault. Mirrors pkg/p2p/message.go's own maxPayloadSize, which bounds the
+// same class of field one level up, at the wire-message layer.
+const maxPayloadSize = 32 << 20 // 32 MiB: far over any legitimate field here.
+
+func readBytes(r io.Reader) ([]byte, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n > maxPayloadSize {
+		return nil, fmt.Errorf("deserialize: length-prefixed field claims %d bytes, over the %d byte limit", n, maxPayloadSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
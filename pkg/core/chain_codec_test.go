@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDeserializeBlockRejectsHugeTxCount builds a payload that claims a
+// transaction count far larger than the bytes actually available and checks
+// that DeserializeBlock fails fast instead of using the bogus count as
+// slice-preallocation capacity, which would otherwise try to allocate tens
+// of gigabytes and crash the process with an unrecoverable out-of-memory
+// fault.
+func TestDeserializeBlockRejectsHugeTxCount(t *testing.T) {
+	var buf bytes.Buffer
+	writeUint32(&buf, CurrentVersion) // version
+	writeUint32(&buf, GenesisId)      // id
+	writeBytes(&buf, nil)             // prevHash
+	writeUint32(&buf, 0xFFFFFFF0)     // txCount: wildly exceeds the payload
+
+	if _, err := DeserializeBlock(buf.Bytes()); err == nil {
+		t.Fatal("DeserializeBlock succeeded on a payload with a bogus tx count, want error")
+	}
+}
+
+// TestDeserializeTransactionRejectsHugeHashCount is the same reproduction
+// against DeserializeTransaction's input-transaction-hash count.
+func TestDeserializeTransactionRejectsHugeHashCount(t *testing.T) {
+	var buf bytes.Buffer
+	writeUint32(&buf, 0xFFFFFFF0) // hashCount: wildly exceeds the payload
+
+	if _, err := DeserializeTransaction(buf.Bytes()); err == nil {
+		t.Fatal("DeserializeTransaction succeeded on a payload with a bogus hash count, want error")
+	}
+}
+
+// TestDeserializeBlockRejectsHugeFieldLength builds a payload that claims a
+// PrevHash field length far larger than maxPayloadSize and checks that
+// DeserializeBlock fails fast instead of passing the bogus length straight
+// to make([]byte, n), which would otherwise try to allocate gigabytes and
+// crash the process with an unrecoverable out-of-memory fault before
+// io.ReadFull ever gets a chance to fail on the short read.
+func TestDeserializeBlockRejectsHugeFieldLength(t *testing.T) {
+	var buf bytes.Buffer
+	writeUint32(&buf, CurrentVersion)   // version
+	writeUint32(&buf, GenesisId)        // id
+	writeUint32(&buf, maxPayloadSize+1) // prevHash length: over the limit
+
+	if _, err := DeserializeBlock(buf.Bytes()); err == nil {
+		t.Fatal("DeserializeBlock succeeded on a payload with a bogus field length, want error")
+	}
+}
@@ -0,0 +1,402 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/albert-zhong/go-coin/pkg/storage"
+)
+
+// emptySource is a TransactionSource with nothing pending, for tests that
+// only care about the coinbase.
+type emptySource struct{}
+
+func (emptySource) GetTopN(n int) []*Transaction { return nil }
+func (emptySource) Remove(hash []byte)           {}
+
+func mineTestGenesis(t *testing.T, chain *Chain) *Block {
+	t.Helper()
+	node := NewNode(chain)
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	block, err := node.Mine(emptySource{}, 0, PubKeyHash(&priv.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	return block
+}
+
+func TestChainRestoresTipAfterRestart(t *testing.T) {
+	backing := storage.NewMemoryStore()
+
+	chain, err := NewChain(backing)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	genesis := mineTestGenesis(t, chain)
+
+	if chain.BlockHeight() != 1 {
+		t.Fatalf("BlockHeight() = %d, want 1", chain.BlockHeight())
+	}
+	if chain.PersistedHeight() != 1 {
+		t.Fatalf("PersistedHeight() = %d, want 1 (default PersistInterval is 1)", chain.PersistedHeight())
+	}
+
+	// Simulate a restart: reopen a fresh Chain on top of the same
+	// backing Store and confirm it resumes from the persisted tip
+	// instead of starting empty.
+	restarted, err := NewChain(backing)
+	if err != nil {
+		t.Fatalf("NewChain (restart): %v", err)
+	}
+	if restarted.BlockHeight() != 1 {
+		t.Fatalf("restarted BlockHeight() = %d, want 1", restarted.BlockHeight())
+	}
+	if restarted.Tip() == nil || string(restarted.Tip().BodyHash) != string(genesis.BodyHash) {
+		t.Fatalf("restarted Tip() = %v, want block with hash %x", restarted.Tip(), genesis.BodyHash)
+	}
+}
+
+// TestChainSurvivesOnDiskRestart is TestChainRestoresTipAfterRestart's
+// genuine counterpart: reusing the same in-process MemoryStore instance
+// across two Chain instances proves nothing about surviving an actual
+// restart, since the data was never written anywhere outside process
+// memory. Here the backing BoltStore is closed and a fresh one reopened
+// against the same on-disk file, so the second Chain can only resume
+// from whatever actually made it to disk.
+func TestChainSurvivesOnDiskRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+
+	backing, err := storage.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	chain, err := NewChain(backing)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	genesis := mineTestGenesis(t, chain)
+
+	if chain.BlockHeight() != 1 {
+		t.Fatalf("BlockHeight() = %d, want 1", chain.BlockHeight())
+	}
+	if err := backing.Close(); err != nil {
+		t.Fatalf("backing.Close: %v", err)
+	}
+
+	reopened, err := storage.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore (reopen): %v", err)
+	}
+	defer reopened.Close()
+
+	restarted, err := NewChain(reopened)
+	if err != nil {
+		t.Fatalf("NewChain (restart): %v", err)
+	}
+	if restarted.BlockHeight() != 1 {
+		t.Fatalf("restarted BlockHeight() = %d, want 1", restarted.BlockHeight())
+	}
+	if restarted.Tip() == nil || string(restarted.Tip().BodyHash) != string(genesis.BodyHash) {
+		t.Fatalf("restarted Tip() = %v, want block with hash %x", restarted.Tip(), genesis.BodyHash)
+	}
+
+	coinbase := genesis.Body.Transactions[0]
+	got, err := restarted.GetTransaction(coinbase.BodyHash)
+	if err != nil {
+		t.Fatalf("GetTransaction after on-disk restart: %v", err)
+	}
+	if string(got.BodyHash) != string(coinbase.BodyHash) {
+		t.Fatalf("GetTransaction returned hash %x, want %x", got.BodyHash, coinbase.BodyHash)
+	}
+}
+
+func TestChainAppendRejectsNonLinkingBlock(t *testing.T) {
+	chain := buildChainWithBlocks(t, 2, time.Minute, 1)
+	tip := chain.Tip()
+
+	stale := buildChildBlock(t, tip, time.Minute, 1)
+	// Mutate the block to no longer match the current tip, as if it had
+	// been built against a tip that's since moved (the race
+	// appendLocked's linkage check guards against).
+	stale.Body.PrevHash = []byte("not the real tip hash")
+
+	if err := chain.Append(stale); err == nil {
+		t.Fatalf("Append did not reject a block whose PrevHash doesn't match the tip")
+	}
+	if string(chain.Tip().BodyHash) != string(tip.BodyHash) {
+		t.Fatalf("Tip() changed despite the rejected Append")
+	}
+}
+
+func TestChainRestoresWithoutWorkKey(t *testing.T) {
+	backing := storage.NewMemoryStore()
+	chain, err := NewChain(backing)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	mineTestGenesis(t, chain)
+
+	// Simulate a chain persisted before WorkKey existed: HeightKey and
+	// friends are there, but WorkKey never got written.
+	if err := backing.Delete(storage.WorkKey()); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	restarted, err := NewChain(backing)
+	if err != nil {
+		t.Fatalf("NewChain (no WorkKey): %v", err)
+	}
+	if restarted.CumulativeWork() != 0 {
+		t.Fatalf("CumulativeWork() = %d, want 0 when WorkKey was never persisted", restarted.CumulativeWork())
+	}
+}
+
+func TestChainGetTransactionAfterPersist(t *testing.T) {
+	chain, err := NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	genesis := mineTestGenesis(t, chain)
+	coinbase := genesis.Body.Transactions[0]
+
+	got, err := chain.GetTransaction(coinbase.BodyHash)
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+	if string(got.BodyHash) != string(coinbase.BodyHash) {
+		t.Fatalf("GetTransaction returned hash %x, want %x", got.BodyHash, coinbase.BodyHash)
+	}
+}
+
+// buildChainWithBlocks appends n blocks directly (bypassing Node.Mine's
+// nonce search) so tests can control block timestamps precisely. It
+// starts at a fixed time rather than time.Now() so timespans are
+// deterministic.
+func buildChainWithBlocks(t *testing.T, n int, blockInterval time.Duration, leadingZeros uint8) *Chain {
+	t.Helper()
+	chain, err := NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var prevHash []byte
+	for id := 0; id < n; id++ {
+		coinbase, err := NewTransaction(&TransactionBody{
+			NextOwner:  PubKeyHash(&priv.PublicKey),
+			InputValue: MiningReward,
+		}, nil, true)
+		if err != nil {
+			t.Fatalf("NewTransaction: %v", err)
+		}
+		block, err := NewBlock(&BlockBody{
+			Version:      CurrentVersion,
+			Id:           uint32(id),
+			PrevHash:     prevHash,
+			Transactions: []*Transaction{coinbase},
+			LeadingZeros: leadingZeros,
+		})
+		if err != nil {
+			t.Fatalf("NewBlock: %v", err)
+		}
+		if err := block.SetFields(0, baseTime.Add(time.Duration(id)*blockInterval)); err != nil {
+			t.Fatalf("SetFields: %v", err)
+		}
+		if err := chain.Append(block); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		prevHash = block.BodyHash
+	}
+	return chain
+}
+
+func TestGetNextWorkRequiredCarriesForwardBetweenRetargets(t *testing.T) {
+	chain := buildChainWithBlocks(t, 5, TargetBlockInterval, 3)
+	got, err := chain.GetNextWorkRequired()
+	if err != nil {
+		t.Fatalf("GetNextWorkRequired: %v", err)
+	}
+	if got != 3 {
+		t.Fatalf("GetNextWorkRequired() = %d, want 3 (unchanged, not a retarget boundary)", got)
+	}
+}
+
+func TestGetNextWorkRequiredIncreasesForFastBlocks(t *testing.T) {
+	chain := buildChainWithBlocks(t, int(RetargetInterval), TargetBlockInterval/8, 4)
+	got, err := chain.GetNextWorkRequired()
+	if err != nil {
+		t.Fatalf("GetNextWorkRequired: %v", err)
+	}
+	if got <= 4 {
+		t.Fatalf("GetNextWorkRequired() = %d, want > 4 (blocks arrived faster than target, difficulty should rise)", got)
+	}
+}
+
+func TestGetNextWorkRequiredDecreasesForSlowBlocks(t *testing.T) {
+	chain := buildChainWithBlocks(t, int(RetargetInterval), TargetBlockInterval*8, 4)
+	got, err := chain.GetNextWorkRequired()
+	if err != nil {
+		t.Fatalf("GetNextWorkRequired: %v", err)
+	}
+	if got >= 4 {
+		t.Fatalf("GetNextWorkRequired() = %d, want < 4 (blocks arrived slower than target, difficulty should fall)", got)
+	}
+}
+
+// buildChildBlock constructs (but doesn't append) a block extending
+// parent, stamped at parentTime+interval. leadingZeros is set directly
+// rather than searched for, so tests can hand AcceptSideBlock blocks of
+// a chosen weight without paying for real proof-of-work.
+func buildChildBlock(t *testing.T, parent *Block, interval time.Duration, leadingZeros uint8) *Block {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	coinbase, err := NewTransaction(&TransactionBody{
+		NextOwner:  PubKeyHash(&priv.PublicKey),
+		InputValue: MiningReward,
+	}, nil, true)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	block, err := NewBlock(&BlockBody{
+		Version:      CurrentVersion,
+		Id:           parent.Body.Id + 1,
+		PrevHash:     parent.BodyHash,
+		Transactions: []*Transaction{coinbase},
+		LeadingZeros: leadingZeros,
+	})
+	if err != nil {
+		t.Fatalf("NewBlock: %v", err)
+	}
+	childTime := parent.Body.Time.Add(interval)
+	var nonce uint32
+	for {
+		if err := block.SetFields(nonce, childTime); err != nil {
+			t.Fatalf("SetFields: %v", err)
+		}
+		if nonceIsValid(block) {
+			break
+		}
+		nonce++
+	}
+	return block
+}
+
+func TestChainAcceptSideBlockIgnoresLighterFork(t *testing.T) {
+	chain := buildChainWithBlocks(t, 3, time.Minute, 1)
+	mainTip := chain.Tip()
+	ancestor, err := chain.GetBlockByID(1)
+	if err != nil {
+		t.Fatalf("GetBlockByID: %v", err)
+	}
+
+	lighterFork := buildChildBlock(t, ancestor, time.Minute, 1)
+	reorged, err := chain.AcceptSideBlock(lighterFork)
+	if err != nil {
+		t.Fatalf("AcceptSideBlock: %v", err)
+	}
+	if reorged {
+		t.Fatalf("AcceptSideBlock reorged onto a fork with less work than the main chain")
+	}
+	if string(chain.Tip().BodyHash) != string(mainTip.BodyHash) {
+		t.Fatalf("Tip() changed despite the lighter fork being rejected")
+	}
+}
+
+func TestChainAcceptSideBlockReorgsToHeavierFork(t *testing.T) {
+	chain := buildChainWithBlocks(t, 3, time.Minute, 1)
+	ancestor, err := chain.GetBlockByID(1)
+	if err != nil {
+		t.Fatalf("GetBlockByID: %v", err)
+	}
+
+	// Main chain's remaining work past the ancestor is one block at
+	// LeadingZeros=1 (Work=2). Each fork block must declare the same
+	// LeadingZeros=1 retargeting actually requires at its position, so a
+	// same-weight first fork block only ties the main chain; a second one
+	// of equal, legitimate difficulty is what pushes the fork ahead and
+	// triggers a reorg.
+	forkBlock1 := buildChildBlock(t, ancestor, time.Minute, 1)
+	forkBlock2 := buildChildBlock(t, forkBlock1, time.Minute, 1)
+
+	if reorged, err := chain.AcceptSideBlock(forkBlock1); err != nil {
+		t.Fatalf("AcceptSideBlock(forkBlock1): %v", err)
+	} else if reorged {
+		t.Fatalf("AcceptSideBlock(forkBlock1) reorged before the fork had more work than the main chain")
+	}
+
+	reorged, err := chain.AcceptSideBlock(forkBlock2)
+	if err != nil {
+		t.Fatalf("AcceptSideBlock(forkBlock2): %v", err)
+	}
+	if !reorged {
+		t.Fatalf("AcceptSideBlock(forkBlock2) did not reorg onto the heavier fork")
+	}
+	if string(chain.Tip().BodyHash) != string(forkBlock2.BodyHash) {
+		t.Fatalf("Tip() = %x after reorg, want forkBlock2 %x", chain.Tip().BodyHash, forkBlock2.BodyHash)
+	}
+	if chain.BlockHeight() != forkBlock2.Body.Id+1 {
+		t.Fatalf("BlockHeight() = %d after reorg, want %d", chain.BlockHeight(), forkBlock2.Body.Id+1)
+	}
+	wantWork := Work(1) + Work(1) + Work(1) + Work(1)
+	if chain.CumulativeWork() != wantWork {
+		t.Fatalf("CumulativeWork() = %d after reorg, want %d", chain.CumulativeWork(), wantWork)
+	}
+}
+
+// TestChainAcceptSideBlockRejectsTriviallyEasyDeclaredDifficulty builds a
+// main chain at real difficulty (LeadingZeros=4) and offers a fork block
+// that self-declares LeadingZeros=0 (nearly free to produce) instead of
+// the 4 retargeting would actually require at that position. Without
+// checking a fork block's declared difficulty against its own fork's
+// history, AcceptSideBlock would accept it on the strength of its
+// self-declared target alone, and an attacker could build an entire
+// competing fork this way, defeating proof-of-work as a security
+// property for fork resolution.
+func TestChainAcceptSideBlockRejectsTriviallyEasyDeclaredDifficulty(t *testing.T) {
+	chain := buildChainWithBlocks(t, 3, time.Minute, 4)
+	ancestor, err := chain.GetBlockByID(1)
+	if err != nil {
+		t.Fatalf("GetBlockByID: %v", err)
+	}
+
+	forkBlock := buildChildBlock(t, ancestor, time.Minute, 0)
+	if _, err := chain.AcceptSideBlock(forkBlock); err == nil {
+		t.Fatal("AcceptSideBlock accepted a fork block declaring a trivially-easy difficulty, want an error")
+	}
+	if mainTip, err := chain.GetBlockByID(2); err != nil || string(chain.Tip().BodyHash) != string(mainTip.BodyHash) {
+		t.Fatalf("Tip() changed despite the trivially-easy fork block being rejected")
+	}
+}
+
+// TestChainAcceptSideBlockRejectsNonMonotonicForkTimestamp offers a fork
+// block whose timestamp is before its parent's, at the correct declared
+// difficulty. A forged gap like this would otherwise feed
+// expectedLeadingZeros's retarget ratio once the fork crosses a retarget
+// boundary, letting a forged timestamp legitimize a lower declared
+// difficulty than honest retargeting on this fork would ever allow.
+func TestChainAcceptSideBlockRejectsNonMonotonicForkTimestamp(t *testing.T) {
+	chain := buildChainWithBlocks(t, 3, time.Minute, 1)
+	ancestor, err := chain.GetBlockByID(1)
+	if err != nil {
+		t.Fatalf("GetBlockByID: %v", err)
+	}
+
+	forkBlock := buildChildBlock(t, ancestor, -time.Minute, 1)
+	if _, err := chain.AcceptSideBlock(forkBlock); err == nil {
+		t.Fatal("AcceptSideBlock accepted a fork block with a timestamp before its parent's, want an error")
+	}
+}
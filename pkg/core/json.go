@@ -0,0 +1,97 @@
+package core
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// IsCoinbase reports whether t is the reward transaction a miner
+// includes as the first transaction of a block, rather than one signed
+// by a client.
+func (t *Transaction) IsCoinbase() bool {
+	return t.isCoinbase
+}
+
+// blockJSON is the wire form Block.MarshalJSON produces: every hash as
+// lowercase hex, matching the rpc package's JSON-RPC responses and
+// Block.Print's hex.EncodeToString convention.
+type blockJSON struct {
+	Hash         string         `json:"hash"`
+	Version      uint32         `json:"version"`
+	Id           uint32         `json:"id"`
+	PrevHash     string         `json:"prevHash"`
+	LeadingZeros uint8          `json:"leadingZeros"`
+	Nonce        uint32         `json:"nonce"`
+	Time         time.Time      `json:"time"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// MarshalJSON encodes b with every hash as hex and its transactions
+// marshaled via Transaction.MarshalJSON, giving the chain a stable JSON
+// form for the rpc package's getblock and submitblock responses.
+func (b *Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(blockJSON{
+		Hash:         hex.EncodeToString(b.BodyHash),
+		Version:      b.Body.Version,
+		Id:           b.Body.Id,
+		PrevHash:     hex.EncodeToString(b.Body.PrevHash),
+		LeadingZeros: b.Body.LeadingZeros,
+		Nonce:        b.Body.Nonce,
+		Time:         b.Body.Time,
+		Transactions: b.Body.Transactions,
+	})
+}
+
+// transactionJSON is the wire form Transaction.MarshalJSON produces.
+// NextOwner is the recipient's pubKeyHash (see PubKeyHash), not a raw
+// public key: since wallet addresses replaced full public keys as
+// transaction destinations, the sender's own signing key is the only
+// public key a transaction still carries, one per OwnerSignature.
+type transactionJSON struct {
+	Hash                   string               `json:"hash"`
+	InputTransactionHashes []string             `json:"inputTransactionHashes"`
+	NextOwner              string               `json:"nextOwner"`
+	InputValue             uint32               `json:"inputValue"`
+	Change                 uint32               `json:"change"`
+	Fee                    uint32               `json:"fee"`
+	IsCoinbase             bool                 `json:"isCoinbase"`
+	OwnerSignatures        []ownerSignatureJSON `json:"ownerSignatures"`
+}
+
+// ownerSignatureJSON is OwnerSignature's wire form: the signer's public
+// key as a compressed-point hex string, alongside its R/S hex pair.
+type ownerSignatureJSON struct {
+	PublicKey string `json:"publicKey"`
+	R         string `json:"r"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes t with every hash and public key as hex, so a
+// client never has to special-case binary fields.
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	inputTransactionHashes := make([]string, len(t.Body.InputTransactionHashes))
+	for i, hash := range t.Body.InputTransactionHashes {
+		inputTransactionHashes[i] = hex.EncodeToString(hash)
+	}
+	ownerSignatures := make([]ownerSignatureJSON, len(t.OwnerSignatures))
+	for i, signature := range t.OwnerSignatures {
+		publicKeyBytes := elliptic.MarshalCompressed(signature.PublicKey.Curve, signature.PublicKey.X, signature.PublicKey.Y)
+		ownerSignatures[i] = ownerSignatureJSON{
+			PublicKey: hex.EncodeToString(publicKeyBytes),
+			R:         hex.EncodeToString(signature.R.Bytes()),
+			S:         hex.EncodeToString(signature.S.Bytes()),
+		}
+	}
+	return json.Marshal(transactionJSON{
+		Hash:                   hex.EncodeToString(t.BodyHash),
+		InputTransactionHashes: inputTransactionHashes,
+		NextOwner:              hex.EncodeToString(t.Body.NextOwner),
+		InputValue:             t.Body.InputValue,
+		Change:                 t.Body.Change,
+		Fee:                    t.Body.Fee,
+		IsCoinbase:             t.isCoinbase,
+		OwnerSignatures:        ownerSignatures,
+	})
+}
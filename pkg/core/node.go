@@ -0,0 +1,302 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+const (
+	GenesisId uint32 = 0
+
+	MiningReward = 10
+)
+
+type Node struct {
+	Chain *Chain
+
+	// Publisher, if set, is notified of every block this Node seals so
+	// it can be announced to the network. It's left nil by NewNode; the
+	// p2p package's Server sets it on the Nodes it drives, while tests
+	// and the standalone example in main.go mine purely locally.
+	Publisher BlockPublisher
+}
+
+func NewNode(chain *Chain) *Node {
+	return &Node{
+		Chain: chain,
+	}
+}
+
+// BlockPublisher lets a Node announce newly sealed blocks to the
+// network. *p2p.Server satisfies this interface; it's expressed here
+// rather than imported to keep core free of a dependency on p2p.
+type BlockPublisher interface {
+	PublishBlock(block *Block)
+}
+
+// TransactionSource supplies candidate transactions for the next block,
+// ordered by priority (e.g. fee-per-byte), and lets the miner drop
+// transactions once they've been sealed into a block. *mempool.Mempool
+// satisfies this interface; it's expressed here rather than imported to
+// keep core free of a dependency on the mempool package.
+type TransactionSource interface {
+	GetTopN(n int) []*Transaction
+	Remove(hash []byte)
+}
+
+// Mine fills a new block with up to maxTransactions of the
+// highest-priority transactions in source, pays their fees plus
+// MiningReward to minerPubKeyHash via the coinbase, seals the block with
+// a valid nonce, appends it to the chain, and removes the included
+// transactions from source.
+// Inductive hypothesis: existing chain is valid.
+func (n *Node) Mine(source TransactionSource, maxTransactions int, minerPubKeyHash []byte) (*Block, error) {
+	candidates := source.GetTopN(maxTransactions)
+
+	var fees uint32
+	for _, transaction := range candidates {
+		fees += transaction.Body.Fee
+	}
+	coinbase, err := NewTransaction(&TransactionBody{
+		NextOwner:  minerPubKeyHash,
+		InputValue: fees + MiningReward,
+	}, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uint32(GenesisId)
+	var prevHash []byte
+	if tip := n.Chain.Tip(); tip != nil {
+		id = tip.Body.Id + 1
+		prevHash = tip.BodyHash
+	}
+	leadingZeros, err := n.Chain.GetNextWorkRequired()
+	if err != nil {
+		return nil, err
+	}
+
+	newBlock, err := n.seal(&BlockBody{
+		Version:      CurrentVersion,
+		Id:           id,
+		PrevHash:     prevHash,
+		Transactions: append([]*Transaction{coinbase}, candidates...),
+		LeadingZeros: leadingZeros,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transaction := range candidates {
+		source.Remove(transaction.BodyHash)
+	}
+	return newBlock, nil
+}
+
+// seal validates newBody except for its nonce, searches for a nonce that
+// satisfies the proof-of-work requirement, and appends the resulting
+// block to the chain.
+func (n *Node) seal(newBody *BlockBody) (*Block, error) {
+	newBlock, err := NewBlock(newBody)
+	if err != nil {
+		return nil, err
+	}
+	// Stamp a real timestamp before verifying: the static BlockBody has
+	// none yet, and the chain-linkage checks below require newBlock's
+	// time to be after the chain tip's.
+	if err = newBlock.SetFields(0, time.Now()); err != nil {
+		return nil, err
+	}
+	if err = verifyExceptNonce(n.Chain, newBlock); err != nil {
+		return nil, err
+	}
+	var nonce uint32 = 0
+	for {
+		if err = newBlock.SetFields(nonce, time.Now()); err != nil {
+			return nil, err
+		}
+		if !nonceIsValid(newBlock) {
+			nonce++
+			if nonce == 0 {
+				// handle integer overflow
+				return nil, fmt.Errorf("new block %s has no valid nonces", newBlock.String())
+			}
+			continue
+		}
+		break
+
+	}
+	if err = n.Chain.Append(newBlock); err != nil {
+		return nil, err
+	}
+	if n.Publisher != nil {
+		n.Publisher.PublishBlock(newBlock)
+	}
+	return newBlock, nil
+}
+
+func nonceIsValid(block *Block) bool {
+	return leadingZeros(block.BodyHash) >= int(block.Body.LeadingZeros)
+}
+
+// VerifyBlock validates block exactly as the miner's own sealing path
+// does (chain linkage, declared difficulty, and transaction rules), plus
+// the proof-of-work check seal's nonce search loop guarantees for
+// locally-mined blocks. It's the entry point for blocks received from
+// peers that claim to extend the current tip; blocks on a competing
+// fork go through Chain.AcceptSideBlock instead, since this function's
+// transaction checks assume the main chain's persisted transaction
+// index.
+func VerifyBlock(chain *Chain, block *Block) error {
+	if err := verifyExceptNonce(chain, block); err != nil {
+		return err
+	}
+	if !nonceIsValid(block) {
+		return fmt.Errorf("block %s nonce %d does not satisfy declared difficulty %d", block.String(), block.Body.Nonce, block.Body.LeadingZeros)
+	}
+	return nil
+}
+
+// AcceptBlock feeds block into chain the same way a freshly mined block
+// does: through VerifyBlock and Append if it extends the current tip, or
+// through Chain.AcceptSideBlock's cumulative-work comparison otherwise.
+// It's the shared entry point for a block arriving from outside the
+// local miner, whether over the network (the p2p package's inbound
+// block handler) or via an RPC client (the rpc package's submitblock).
+func AcceptBlock(chain *Chain, block *Block) error {
+	tip := chain.Tip()
+	extendsTip := tip == nil || bytes.Equal(block.Body.PrevHash, tip.BodyHash)
+	if extendsTip {
+		if err := VerifyBlock(chain, block); err != nil {
+			return err
+		}
+		return chain.Append(block)
+	}
+	_, err := chain.AcceptSideBlock(block)
+	return err
+}
+
+func leadingZeros(data []byte) int {
+	count := 0
+	for _, b := range data {
+		if b == 0 {
+			count += 8
+		} else {
+			count += bits.LeadingZeros8(b)
+			break
+		}
+	}
+	return count
+}
+
+func verifyExceptNonce(chain *Chain, newBlock *Block) error {
+	if newBlock.Body.Version != CurrentVersion {
+		return fmt.Errorf("new block %s has invalid version %d, want %d", newBlock.String(), newBlock.Body.Version, CurrentVersion)
+	}
+	expectedLeadingZeros, err := chain.GetNextWorkRequired()
+	if err != nil {
+		return err
+	}
+	if newBlock.Body.LeadingZeros != expectedLeadingZeros {
+		return fmt.Errorf("new block %s declares leading zeros %d, want %d", newBlock.String(), newBlock.Body.LeadingZeros, expectedLeadingZeros)
+	}
+	if headBlock := chain.Tip(); headBlock != nil {
+		if newBlock.Body.Id != headBlock.Body.Id+1 {
+			return fmt.Errorf("new block %s has invalid id %d, want %d", newBlock.String(), newBlock.Body.Id, headBlock.Body.Id+1)
+		}
+		if !bytes.Equal(newBlock.Body.PrevHash, headBlock.BodyHash) {
+			return fmt.Errorf("new block %s has invalid prev hash %s, not equal to head block hash %s", newBlock.String(), string(newBlock.Body.PrevHash), string(headBlock.BodyHash))
+		}
+		if !newBlock.Body.Time.After(headBlock.Body.Time) {
+			return fmt.Errorf("new block %s has invalid timestamp %s, not after head block timestamp %s", newBlock.String(), newBlock.Body.Time, headBlock.Body.Time)
+		}
+	} else {
+		if newBlock.Body.Id != GenesisId {
+			return fmt.Errorf("new block %s is genesis block, want id %d", newBlock.String(), GenesisId)
+		}
+	}
+
+	now := time.Now()
+	if newBlock.Body.Time.After(now) {
+		return fmt.Errorf("new block %s has invalid timestamp %s, not before or equal to now %s", newBlock.String(), newBlock.Body.Time, now)
+	}
+
+	if len(newBlock.Body.Transactions) == 0 {
+		return fmt.Errorf("new block %s contains no transactions", newBlock.String())
+	}
+
+	// Transactions introduced earlier in this same block (the coinbase,
+	// and any non-coinbase transaction already validated below) aren't in
+	// the persisted index yet, so they're tracked locally and consulted
+	// before falling back to chain.GetTransaction.
+	localTransactions := make(map[string]*Transaction)
+	coinBaseTransaction := newBlock.Body.Transactions[0]
+	localTransactions[coinBaseTransaction.String()] = coinBaseTransaction
+	var newBlockFees uint32
+
+	lookupTransaction := func(hash []byte) (*Transaction, error) {
+		if transaction, ok := localTransactions[hex.EncodeToString(hash)]; ok {
+			return transaction, nil
+		}
+		return chain.GetTransaction(hash)
+	}
+
+	// spentInputs tracks every input transaction hash consumed so far by
+	// this block, so a second transaction spending an output already spent
+	// earlier in the same block is rejected instead of silently accepted;
+	// without this, a block could mint unbacked coins by spending the same
+	// input more than once.
+	spentInputs := make(map[string]bool)
+
+	// Verify non-coinbase transactions
+	for _, transaction := range newBlock.Body.Transactions[1:] {
+		if len(transaction.Body.InputTransactionHashes) != len(transaction.OwnerSignatures) {
+			return fmt.Errorf("new block %s txn %s has %d input txns != %d owner signatures", newBlock.String(), transaction.String(), len(transaction.Body.InputTransactionHashes), len(transaction.OwnerSignatures))
+		}
+		var expectedInputValue uint32
+		for i, inputTransactionHash := range transaction.Body.InputTransactionHashes {
+			inputTransaction, err := lookupTransaction(inputTransactionHash)
+			if err != nil {
+				return fmt.Errorf("new block %s txn %s references input txn %s that does not exist", newBlock.String(), hex.EncodeToString(inputTransactionHash), transaction.String())
+			}
+			inputKey := hex.EncodeToString(inputTransactionHash)
+			if spentInputs[inputKey] {
+				return fmt.Errorf("new block %s txn %s double-spends input txn %s", newBlock.String(), transaction.String(), inputKey)
+			}
+			if spent, err := chain.IsSpent(inputTransactionHash); err != nil {
+				return err
+			} else if spent {
+				return fmt.Errorf("new block %s txn %s spends input txn %s already spent by a previously appended block", newBlock.String(), transaction.String(), inputKey)
+			}
+			inputOwnerSignature := transaction.OwnerSignatures[i]
+			if !bytes.Equal(PubKeyHash(inputOwnerSignature.PublicKey), inputTransaction.Body.NextOwner) {
+				return fmt.Errorf("new block %s txn %s signature %d's public key does not hash to input txn %s's next owner", newBlock.String(), transaction.String(), i, inputTransaction.String())
+			}
+			if !ecdsa.Verify(inputOwnerSignature.PublicKey, transaction.BodyHash, inputOwnerSignature.R, inputOwnerSignature.S) {
+				return fmt.Errorf("new block %s txn %s contains invalid signature", newBlock.String(), transaction.String())
+			}
+			spentInputs[inputKey] = true
+			expectedInputValue += inputTransaction.OutputValue()
+		}
+		if expectedInputValue != transaction.Body.InputValue {
+			return fmt.Errorf("new block %s txn %s has unexpected input value %d, want %d", newBlock.String(), transaction.String(), transaction.Body.InputValue, expectedInputValue)
+		}
+		if transaction.Body.InputValue < transaction.Body.Change+transaction.Body.Fee {
+			return fmt.Errorf("new block %s txn %s has input value %d less than change + fee %d", newBlock.String(), transaction.String(), transaction.Body.InputValue, transaction.Body.Change+transaction.Body.Fee)
+		}
+		// transaction is now valid
+		localTransactions[transaction.String()] = transaction
+		newBlockFees += transaction.Body.Fee
+	}
+
+	// Verify coinbase transaction
+	if coinBaseTransaction.Body.InputValue != newBlockFees+MiningReward {
+		return fmt.Errorf("new block %s coinbase txn has input value %d, expected %d", newBlock.String(), coinBaseTransaction.Body.InputValue, newBlockFees+MiningReward)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+// TestVerifyExceptNonceRejectsDoubleSpendWithinBlock builds a block that
+// spends the same coinbase output to two different recipients in two
+// separate transactions, and checks VerifyBlock rejects it. Without
+// tracking which inputs a block has already spent, the second transaction
+// would pass every other check and the block would mint coins it never
+// actually had.
+func TestVerifyExceptNonceRejectsDoubleSpendWithinBlock(t *testing.T) {
+	minerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	chain, err := NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := NewNode(chain)
+	genesis, err := node.Mine(emptySource{}, 0, PubKeyHash(&minerPriv.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	coinbase := genesis.Body.Transactions[0]
+
+	recipient1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	recipient2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	spend := func(nextOwner []byte) *Transaction {
+		transaction, err := NewTransaction(&TransactionBody{
+			InputTransactionHashes: [][]byte{coinbase.BodyHash},
+			NextOwner:              nextOwner,
+			InputValue:             MiningReward,
+		}, []*ecdsa.PrivateKey{minerPriv}, false)
+		if err != nil {
+			t.Fatalf("NewTransaction: %v", err)
+		}
+		return transaction
+	}
+	spend1 := spend(PubKeyHash(&recipient1.PublicKey))
+	spend2 := spend(PubKeyHash(&recipient2.PublicKey))
+
+	newCoinbase, err := NewTransaction(&TransactionBody{
+		NextOwner:  PubKeyHash(&minerPriv.PublicKey),
+		InputValue: MiningReward,
+	}, nil, true)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	leadingZeros, err := chain.GetNextWorkRequired()
+	if err != nil {
+		t.Fatalf("GetNextWorkRequired: %v", err)
+	}
+	block, err := NewBlock(&BlockBody{
+		Version:      CurrentVersion,
+		Id:           genesis.Body.Id + 1,
+		PrevHash:     genesis.BodyHash,
+		Transactions: []*Transaction{newCoinbase, spend1, spend2},
+		LeadingZeros: leadingZeros,
+	})
+	if err != nil {
+		t.Fatalf("NewBlock: %v", err)
+	}
+	if err := block.SetFields(0, genesis.Body.Time.Add(1)); err != nil {
+		t.Fatalf("SetFields: %v", err)
+	}
+
+	if err := verifyExceptNonce(chain, block); err == nil {
+		t.Fatal("verifyExceptNonce accepted a block double-spending the same input, want error")
+	}
+}
+
+// fixedSource is a TransactionSource that always offers the same
+// transactions, for tests that want Mine to seal a specific transaction
+// rather than whatever a real Mempool happens to hold.
+type fixedSource struct {
+	transactions []*Transaction
+}
+
+func (s fixedSource) GetTopN(n int) []*Transaction {
+	if n > len(s.transactions) {
+		n = len(s.transactions)
+	}
+	return s.transactions[:n]
+}
+
+func (fixedSource) Remove(hash []byte) {}
+
+// TestVerifyExceptNonceRejectsDoubleSpendAcrossBlocks mines a coinbase,
+// spends it to one recipient in the next block (accepted), then tries to
+// spend the same coinbase again to a different recipient in the block
+// after that. Without a persisted spent index, nothing would notice the
+// input was already consumed by an earlier, already-appended block.
+func TestVerifyExceptNonceRejectsDoubleSpendAcrossBlocks(t *testing.T) {
+	minerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	chain, err := NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := NewNode(chain)
+	genesis, err := node.Mine(emptySource{}, 0, PubKeyHash(&minerPriv.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	coinbase := genesis.Body.Transactions[0]
+
+	recipient1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	recipient2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	spend := func(nextOwner []byte) *Transaction {
+		transaction, err := NewTransaction(&TransactionBody{
+			InputTransactionHashes: [][]byte{coinbase.BodyHash},
+			NextOwner:              nextOwner,
+			InputValue:             MiningReward,
+		}, []*ecdsa.PrivateKey{minerPriv}, false)
+		if err != nil {
+			t.Fatalf("NewTransaction: %v", err)
+		}
+		return transaction
+	}
+	spend1 := spend(PubKeyHash(&recipient1.PublicKey))
+	if _, err := node.Mine(fixedSource{transactions: []*Transaction{spend1}}, 1, PubKeyHash(&minerPriv.PublicKey)); err != nil {
+		t.Fatalf("Mine spend1: %v", err)
+	}
+
+	spend2 := spend(PubKeyHash(&recipient2.PublicKey))
+	if _, err := node.Mine(fixedSource{transactions: []*Transaction{spend2}}, 1, PubKeyHash(&minerPriv.PublicKey)); err == nil {
+		t.Fatal("Mine accepted a block re-spending an input already spent by a previous block, want error")
+	}
+}
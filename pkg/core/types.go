@@ -1,4 +1,4 @@
-package main
+package core
 
 import (
 	"crypto/rand"
@@ -12,22 +12,14 @@ import (
 
 	"crypto/ecdsa"
 	"crypto/elliptic"
+
+	"golang.org/x/crypto/ripemd160"
 )
 
 const (
 	CurrentVersion = 1
 )
 
-type Chain struct {
-	Blocks []*Block
-}
-
-func NewChain(blocks []*Block) *Chain {
-	return &Chain{
-		Blocks: blocks,
-	}
-}
-
 type Block struct {
 	Body     *BlockBody
 	BodyHash []byte
@@ -82,6 +74,7 @@ func (b *Block) Print() {
 	fmt.Printf("block %s\n", hex.EncodeToString(b.BodyHash))
 	fmt.Printf("  id %d\n", b.Body.Id)
 	fmt.Printf("  prev hash %s\n", hex.EncodeToString(b.Body.PrevHash))
+	fmt.Printf("  leading zeros %d\n", b.Body.LeadingZeros)
 	fmt.Printf("  nonce %d\n", b.Body.Nonce)
 	fmt.Printf("  time %s\n", b.Body.Time)
 	fmt.Printf("  %d txns\n", len(b.Body.Transactions))
@@ -91,7 +84,7 @@ func (b *Block) Print() {
 		for _, inputTransactionHash := range transaction.Body.InputTransactionHashes {
 			inputTransactionHashStrings = append(inputTransactionHashStrings, hex.EncodeToString(inputTransactionHash))
 		}
-		nextOwnerString := hex.EncodeToString(elliptic.MarshalCompressed(transaction.Body.NextOwner.Curve, transaction.Body.NextOwner.X, transaction.Body.NextOwner.Y))
+		nextOwnerString := hex.EncodeToString(transaction.Body.NextOwner)
 		fmt.Printf("      input txns [ %s ]\n", strings.Join(inputTransactionHashStrings, " "))
 		fmt.Printf("      next owner %s\n", nextOwnerString)
 		fmt.Printf("      input value %d\n", transaction.Body.InputValue)
@@ -105,6 +98,11 @@ type BlockBody struct {
 	Id           uint32
 	PrevHash     []byte
 	Transactions []*Transaction
+	// LeadingZeros is the proof-of-work difficulty required of this
+	// block's hash, set by Chain.GetNextWorkRequired at mining time and
+	// re-derived from history by verifyExceptNonce so a block can't lie
+	// about its own difficulty.
+	LeadingZeros uint8
 
 	// non-static fields
 	Nonce uint32
@@ -145,6 +143,9 @@ func (b *BlockBody) staticHash() ([]byte, error) {
 			return nil, err
 		}
 	}
+	if _, err := h.Write([]byte{b.LeadingZeros}); err != nil {
+		return nil, err
+	}
 	// skip non-static fields (nonce, time)
 	return h.Sum(nil), nil
 }
@@ -169,8 +170,9 @@ func NewTransaction(body *TransactionBody, privs []*ecdsa.PrivateKey, isCoinbase
 			return nil, err
 		}
 		ownerSignature := OwnerSignature{
-			R: r,
-			S: s,
+			PublicKey: &priv.PublicKey,
+			R:         r,
+			S:         s,
 		}
 		ownerSignatures = append(ownerSignatures, ownerSignature)
 	}
@@ -189,10 +191,14 @@ func (t *Transaction) String() string {
 
 type TransactionBody struct {
 	InputTransactionHashes [][]byte
-	NextOwner              *ecdsa.PublicKey
-	InputValue             uint32
-	Change                 uint32
-	Fee                    uint32
+	// NextOwner is the pubKeyHash (see PubKeyHash) of the recipient, not
+	// their raw public key: the recipient doesn't need to be known to
+	// anyone but the sender at send time, only the hash of their address
+	// does.
+	NextOwner  []byte
+	InputValue uint32
+	Change     uint32
+	Fee        uint32
 }
 
 func (t *TransactionBody) hash() ([]byte, error) {
@@ -202,7 +208,7 @@ func (t *TransactionBody) hash() ([]byte, error) {
 			return nil, err
 		}
 	}
-	if _, err := h.Write(elliptic.MarshalCompressed(t.NextOwner.Curve, t.NextOwner.X, t.NextOwner.Y)); err != nil {
+	if _, err := h.Write(t.NextOwner); err != nil {
 		return nil, err
 	}
 	if err := binary.Write(h, binary.LittleEndian, t.InputValue); err != nil {
@@ -225,6 +231,22 @@ func (t *Transaction) OutputValue() uint32 {
 }
 
 type OwnerSignature struct {
-	R *big.Int
-	S *big.Int
+	// PublicKey is the signer's public key, presented alongside the
+	// signature so a verifier can check that it hashes (via PubKeyHash)
+	// to the referenced input's NextOwner before trusting R, S.
+	PublicKey *ecdsa.PublicKey
+	R         *big.Int
+	S         *big.Int
+}
+
+// PubKeyHash derives the address hash for pub: ripemd160(sha256(pubkey)),
+// the same scheme Bitcoin and its derivatives use. TransactionBody.NextOwner
+// stores this hash rather than a raw public key.
+func PubKeyHash(pub *ecdsa.PublicKey) []byte {
+	pubKeyBytes := elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)
+	shaHash := sha256.Sum256(pubKeyBytes)
+	hasher := ripemd160.New()
+	// ripemd160.Write never returns an error.
+	hasher.Write(shaHash[:])
+	return hasher.Sum(nil)
 }
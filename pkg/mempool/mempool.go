@@ -0,0 +1,213 @@
+// Package mempool holds transactions that have been submitted by clients
+// but not yet mined into a block. It validates each transaction against
+// the chain tip on arrival and orders accepted transactions by
+// fee-per-byte so a miner can greedily fill a block with the most
+// valuable ones first, following the shape of neo-go's pkg/core/mempool.
+package mempool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+)
+
+// defaultMemPoolSize bounds how many pending transactions are held at
+// once. Once full, Add evicts the lowest fee-per-byte transaction to make
+// room for a higher-paying one.
+const defaultMemPoolSize = 50000
+
+var (
+	// ErrAlreadyExists is returned by Add when the transaction is already
+	// in the pool.
+	ErrAlreadyExists = errors.New("mempool: transaction already exists")
+	// ErrOOM is returned by Add when the pool is at capacity and the new
+	// transaction doesn't pay enough to evict the cheapest entry.
+	ErrOOM = errors.New("mempool: at capacity")
+)
+
+type item struct {
+	transaction *core.Transaction
+	feePerByte  float64
+}
+
+// Mempool is a concurrent-safe pool of pending transactions, ordered by
+// fee-per-byte.
+type Mempool struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*item
+	// spentBy tracks, for each input transaction hash referenced by a
+	// pending transaction, the hash of the pending transaction that
+	// spends it, so a conflicting double-spend can't enter the pool.
+	spentBy map[string]string
+}
+
+// New creates an empty Mempool with the default capacity.
+func New() *Mempool {
+	return NewWithCapacity(defaultMemPoolSize)
+}
+
+// NewWithCapacity creates an empty Mempool that holds at most capacity
+// transactions.
+func NewWithCapacity(capacity int) *Mempool {
+	return &Mempool{
+		capacity: capacity,
+		items:    make(map[string]*item),
+		spentBy:  make(map[string]string),
+	}
+}
+
+// Add validates transaction against chain and, if it's valid and not a
+// conflicting double-spend of another pending transaction, inserts it
+// into the pool. If the pool is at capacity, the lowest fee-per-byte
+// transaction is evicted to make room, provided transaction pays more
+// than it; otherwise Add returns ErrOOM.
+func (m *Mempool) Add(chain *core.Chain, transaction *core.Transaction) error {
+	key := string(transaction.BodyHash)
+	feePerByte, err := feePerByte(transaction)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.items[key]; ok {
+		return ErrAlreadyExists
+	}
+	if err := m.verifyLocked(chain, transaction); err != nil {
+		return err
+	}
+
+	if len(m.items) >= m.capacity {
+		lowestKey, lowest := m.lowestLocked()
+		if lowest == nil || feePerByte <= lowest.feePerByte {
+			return ErrOOM
+		}
+		m.removeLocked(lowestKey)
+	}
+
+	m.items[key] = &item{transaction: transaction, feePerByte: feePerByte}
+	for _, inputHash := range transaction.Body.InputTransactionHashes {
+		m.spentBy[string(inputHash)] = key
+	}
+	return nil
+}
+
+// Remove drops the transaction with the given hash from the pool, if
+// present. It's a no-op otherwise, so a miner can call it unconditionally
+// once a block has been sealed.
+func (m *Mempool) Remove(hash []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(string(hash))
+}
+
+// GetTopN returns up to n pending transactions ordered by descending
+// fee-per-byte, the set a miner should prefer to include next.
+func (m *Mempool) GetTopN(n int) []*core.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := make([]*item, 0, len(m.items))
+	for _, it := range m.items {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].feePerByte > items[j].feePerByte
+	})
+	if n > len(items) {
+		n = len(items)
+	}
+	transactions := make([]*core.Transaction, n)
+	for i := 0; i < n; i++ {
+		transactions[i] = items[i].transaction
+	}
+	return transactions
+}
+
+// Len returns the number of transactions currently pending.
+func (m *Mempool) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+func (m *Mempool) removeLocked(key string) {
+	it, ok := m.items[key]
+	if !ok {
+		return
+	}
+	for _, inputHash := range it.transaction.Body.InputTransactionHashes {
+		delete(m.spentBy, string(inputHash))
+	}
+	delete(m.items, key)
+}
+
+func (m *Mempool) lowestLocked() (string, *item) {
+	var lowestKey string
+	var lowest *item
+	for key, it := range m.items {
+		if lowest == nil || it.feePerByte < lowest.feePerByte {
+			lowestKey, lowest = key, it
+		}
+	}
+	return lowestKey, lowest
+}
+
+// verifyLocked checks transaction against the chain tip: its signature(s)
+// verify against the owner of each referenced input, the input value
+// matches the sum of what those inputs are worth, InputValue covers
+// Change+Fee, and that no referenced input is already spent, whether by
+// another pending transaction or by a block already on the chain.
+func (m *Mempool) verifyLocked(chain *core.Chain, transaction *core.Transaction) error {
+	if len(transaction.Body.InputTransactionHashes) != len(transaction.OwnerSignatures) {
+		return fmt.Errorf("mempool: txn %s has %d input txns != %d owner signatures", transaction.String(), len(transaction.Body.InputTransactionHashes), len(transaction.OwnerSignatures))
+	}
+	var inputValue uint32
+	for i, inputHash := range transaction.Body.InputTransactionHashes {
+		if spender, ok := m.spentBy[string(inputHash)]; ok {
+			return fmt.Errorf("mempool: txn %s input %x already spent by pending txn %x", transaction.String(), inputHash, spender)
+		}
+		if spent, err := chain.IsSpent(inputHash); err != nil {
+			return fmt.Errorf("mempool: txn %s: %w", transaction.String(), err)
+		} else if spent {
+			return fmt.Errorf("mempool: txn %s input %x already spent by a persisted block", transaction.String(), inputHash)
+		}
+		inputTransaction, err := chain.GetTransaction(inputHash)
+		if err != nil {
+			return fmt.Errorf("mempool: txn %s references input txn %x that does not exist: %w", transaction.String(), inputHash, err)
+		}
+		signature := transaction.OwnerSignatures[i]
+		if !bytes.Equal(core.PubKeyHash(signature.PublicKey), inputTransaction.Body.NextOwner) {
+			return fmt.Errorf("mempool: txn %s signature %d's public key does not hash to input txn %s's next owner", transaction.String(), i, inputTransaction.String())
+		}
+		if !ecdsa.Verify(signature.PublicKey, transaction.BodyHash, signature.R, signature.S) {
+			return fmt.Errorf("mempool: txn %s contains invalid signature", transaction.String())
+		}
+		inputValue += inputTransaction.OutputValue()
+	}
+	if inputValue != transaction.Body.InputValue {
+		return fmt.Errorf("mempool: txn %s has unexpected input value %d, want %d", transaction.String(), transaction.Body.InputValue, inputValue)
+	}
+	if transaction.Body.InputValue < transaction.Body.Change+transaction.Body.Fee {
+		return fmt.Errorf("mempool: txn %s has input value %d less than change + fee %d", transaction.String(), transaction.Body.InputValue, transaction.Body.Change+transaction.Body.Fee)
+	}
+	return nil
+}
+
+func feePerByte(transaction *core.Transaction) (float64, error) {
+	data, err := transaction.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	return float64(transaction.Body.Fee) / float64(len(data)), nil
+}
@@ -0,0 +1,195 @@
+package mempool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+)
+
+// emptySource lets tests seal a coinbase-only block without pulling in
+// the mempool itself.
+type emptySource struct{}
+
+func (emptySource) GetTopN(n int) []*core.Transaction { return nil }
+func (emptySource) Remove(hash []byte)                {}
+
+func newTestChainWithCoinbase(t *testing.T) (*core.Chain, *core.Transaction, *ecdsa.PrivateKey) {
+	t.Helper()
+	chain, coinbase1, priv1, _, _ := newTestChainWithTwoCoinbases(t)
+	return chain, coinbase1, priv1
+}
+
+// newTestChainWithTwoCoinbases mines two blocks against an empty mempool,
+// giving tests two distinct, unspent coinbase outputs to build
+// transactions from.
+func newTestChainWithTwoCoinbases(t *testing.T) (chain *core.Chain, coinbase1 *core.Transaction, priv1 *ecdsa.PrivateKey, coinbase2 *core.Transaction, priv2 *ecdsa.PrivateKey) {
+	t.Helper()
+	chain, err := core.NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := core.NewNode(chain)
+
+	priv1, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	block1, err := node.Mine(emptySource{}, 0, core.PubKeyHash(&priv1.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	priv2, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	block2, err := node.Mine(emptySource{}, 0, core.PubKeyHash(&priv2.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	return chain, block1.Body.Transactions[0], priv1, block2.Body.Transactions[0], priv2
+}
+
+func newSpendingTransaction(t *testing.T, coinbase *core.Transaction, priv *ecdsa.PrivateKey, change, fee uint32) *core.Transaction {
+	t.Helper()
+	recipientPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	transaction, err := core.NewTransaction(&core.TransactionBody{
+		InputTransactionHashes: [][]byte{coinbase.BodyHash},
+		NextOwner:              core.PubKeyHash(&recipientPriv.PublicKey),
+		InputValue:             coinbase.OutputValue(),
+		Change:                 change,
+		Fee:                    fee,
+	}, []*ecdsa.PrivateKey{priv}, false)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	return transaction
+}
+
+func TestMempoolAddAndGetTopN(t *testing.T) {
+	chain, coinbase, priv := newTestChainWithCoinbase(t)
+	pool := New()
+
+	transaction := newSpendingTransaction(t, coinbase, priv, 5, 2)
+	if err := pool.Add(chain, transaction); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if pool.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", pool.Len())
+	}
+
+	top := pool.GetTopN(10)
+	if len(top) != 1 || string(top[0].BodyHash) != string(transaction.BodyHash) {
+		t.Fatalf("GetTopN(10) = %v, want [%x]", top, transaction.BodyHash)
+	}
+}
+
+func TestMempoolAddRejectsDuplicate(t *testing.T) {
+	chain, coinbase, priv := newTestChainWithCoinbase(t)
+	pool := New()
+	transaction := newSpendingTransaction(t, coinbase, priv, 5, 0)
+
+	if err := pool.Add(chain, transaction); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := pool.Add(chain, transaction); err != ErrAlreadyExists {
+		t.Fatalf("second Add err = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestMempoolAddRejectsUnknownInput(t *testing.T) {
+	chain, _, priv := newTestChainWithCoinbase(t)
+	pool := New()
+
+	// A coinbase that was never mined into chain, so its hash isn't in
+	// the transaction index. A distinct InputValue keeps its BodyHash
+	// from accidentally colliding with the real genesis coinbase.
+	ghostCoinbase, err := core.NewTransaction(&core.TransactionBody{
+		NextOwner:  core.PubKeyHash(&priv.PublicKey),
+		InputValue: core.MiningReward + 1,
+	}, nil, true)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	transaction := newSpendingTransaction(t, ghostCoinbase, priv, 5, 0)
+
+	if err := pool.Add(chain, transaction); err == nil {
+		t.Fatalf("Add with unknown input txn: got nil error, want one")
+	}
+}
+
+func TestMempoolRemove(t *testing.T) {
+	chain, coinbase, priv := newTestChainWithCoinbase(t)
+	pool := New()
+	transaction := newSpendingTransaction(t, coinbase, priv, 5, 0)
+
+	if err := pool.Add(chain, transaction); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	pool.Remove(transaction.BodyHash)
+	if pool.Len() != 0 {
+		t.Fatalf("Len() after Remove = %d, want 0", pool.Len())
+	}
+}
+
+// TestMempoolAddRejectsInputAlreadySpentOnChain mines a transaction that
+// spends a coinbase, then tries to add a second transaction spending that
+// same coinbase to a different recipient. spentBy only guards conflicts
+// among pool-pending transactions, so without consulting the chain's own
+// spent index this second transaction would be accepted outright.
+func TestMempoolAddRejectsInputAlreadySpentOnChain(t *testing.T) {
+	chain, coinbase, priv := newTestChainWithCoinbase(t)
+	node := core.NewNode(chain)
+	pool := New()
+
+	first := newSpendingTransaction(t, coinbase, priv, 5, 0)
+	if err := pool.Add(chain, first); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	minerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, err := node.Mine(pool, 1, core.PubKeyHash(&minerPriv.PublicKey)); err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	second := newSpendingTransaction(t, coinbase, priv, 5, 0)
+	if err := pool.Add(chain, second); err == nil {
+		t.Fatal("Add accepted a transaction spending an input already spent on chain, want error")
+	}
+}
+
+func TestMempoolEvictsLowestFeeOnOOM(t *testing.T) {
+	chain, coinbase1, priv1, coinbase2, priv2 := newTestChainWithTwoCoinbases(t)
+	pool := NewWithCapacity(1)
+
+	low := newSpendingTransaction(t, coinbase1, priv1, 9, 1)
+	if err := pool.Add(chain, low); err != nil {
+		t.Fatalf("Add(low): %v", err)
+	}
+
+	high := newSpendingTransaction(t, coinbase2, priv2, 5, 5)
+	if err := pool.Add(chain, high); err != nil {
+		t.Fatalf("Add(high) should evict low: %v", err)
+	}
+	if pool.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", pool.Len())
+	}
+	top := pool.GetTopN(10)
+	if len(top) != 1 || string(top[0].BodyHash) != string(high.BodyHash) {
+		t.Fatalf("GetTopN(10) = %v, want [%x] (low should have been evicted)", top, high.BodyHash)
+	}
+
+	lower := newSpendingTransaction(t, coinbase1, priv1, 9, 1)
+	if err := pool.Add(chain, lower); err != ErrOOM {
+		t.Fatalf("Add(lower) while high occupies the only slot = %v, want ErrOOM", err)
+	}
+}
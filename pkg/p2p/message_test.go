@@ -0,0 +1,211 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+)
+
+// mineTestGenesis mines a genesis block on a fresh in-memory chain, for
+// tests that need a real *core.Block/*core.Transaction to round-trip.
+func mineTestGenesis(t *testing.T) *core.Block {
+	t.Helper()
+	chain, err := core.NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := core.NewNode(chain)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	block, err := node.Mine(mempool.New(), 0, core.PubKeyHash(&priv.PublicKey))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	return block
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, MessageTypePing, []byte("payload")); err != nil {
+		t.Fatalf("writeFrame: %v", err)
+	}
+	msgType, payload, err := readFrame(&buf)
+	if err != nil {
+		t.Fatalf("readFrame: %v", err)
+	}
+	if msgType != MessageTypePing {
+		t.Fatalf("msgType = %v, want %v", msgType, MessageTypePing)
+	}
+	if string(payload) != "payload" {
+		t.Fatalf("payload = %q, want %q", payload, "payload")
+	}
+}
+
+func TestVersionRoundTrip(t *testing.T) {
+	want := &VersionMessage{ProtocolVersion: ProtocolVersion, BlockHeight: 42}
+	encoded, err := encodeVersion(want)
+	if err != nil {
+		t.Fatalf("encodeVersion: %v", err)
+	}
+	got, err := decodeVersion(encoded)
+	if err != nil {
+		t.Fatalf("decodeVersion: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("decodeVersion() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetHeadersRoundTrip(t *testing.T) {
+	want := &GetHeadersMessage{FromId: 7}
+	encoded, err := encodeGetHeaders(want)
+	if err != nil {
+		t.Fatalf("encodeGetHeaders: %v", err)
+	}
+	got, err := decodeGetHeaders(encoded)
+	if err != nil {
+		t.Fatalf("decodeGetHeaders: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("decodeGetHeaders() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHeadersRoundTrip(t *testing.T) {
+	genesis := mineTestGenesis(t)
+	want := &HeadersMessage{Blocks: []*core.Block{genesis}}
+	encoded, err := encodeHeaders(want)
+	if err != nil {
+		t.Fatalf("encodeHeaders: %v", err)
+	}
+	got, err := decodeHeaders(encoded)
+	if err != nil {
+		t.Fatalf("decodeHeaders: %v", err)
+	}
+	if len(got.Blocks) != 1 || string(got.Blocks[0].BodyHash) != string(genesis.BodyHash) {
+		t.Fatalf("decodeHeaders() = %+v, want one block with hash %x", got.Blocks, genesis.BodyHash)
+	}
+}
+
+// TestDecodeHeadersRejectsHugeCount builds a payload that claims a block
+// count far larger than the bytes actually available and checks that
+// decodeHeaders fails fast instead of using the bogus count as
+// slice-preallocation capacity, which would otherwise try to allocate tens
+// of gigabytes and crash the process with an unrecoverable out-of-memory
+// fault.
+func TestDecodeHeadersRejectsHugeCount(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeUint32(&buf, 0xFFFFFFF0); err != nil {
+		t.Fatalf("writeUint32: %v", err)
+	}
+	if _, err := decodeHeaders(buf.Bytes()); err == nil {
+		t.Fatal("decodeHeaders succeeded on a payload with a bogus block count, want error")
+	}
+}
+
+// TestDecodeInvRejectsHugeCount is the same reproduction against
+// decodeInvKind's hash count, reached through decodeInv.
+func TestDecodeInvRejectsHugeCount(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(InvKindBlock))
+	if err := writeUint32(&buf, 0xFFFFFFF0); err != nil {
+		t.Fatalf("writeUint32: %v", err)
+	}
+	if _, err := decodeInv(buf.Bytes()); err == nil {
+		t.Fatal("decodeInv succeeded on a payload with a bogus hash count, want error")
+	}
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	genesis := mineTestGenesis(t)
+	encoded, err := encodeBlock(&BlockMessage{Block: genesis})
+	if err != nil {
+		t.Fatalf("encodeBlock: %v", err)
+	}
+	got, err := decodeBlock(encoded)
+	if err != nil {
+		t.Fatalf("decodeBlock: %v", err)
+	}
+	if string(got.Block.BodyHash) != string(genesis.BodyHash) {
+		t.Fatalf("decodeBlock() hash = %x, want %x", got.Block.BodyHash, genesis.BodyHash)
+	}
+}
+
+func TestTxRoundTrip(t *testing.T) {
+	genesis := mineTestGenesis(t)
+	coinbase := genesis.Body.Transactions[0]
+	encoded, err := encodeTx(&TxMessage{Transaction: coinbase})
+	if err != nil {
+		t.Fatalf("encodeTx: %v", err)
+	}
+	got, err := decodeTx(encoded)
+	if err != nil {
+		t.Fatalf("decodeTx: %v", err)
+	}
+	if string(got.Transaction.BodyHash) != string(coinbase.BodyHash) {
+		t.Fatalf("decodeTx() hash = %x, want %x", got.Transaction.BodyHash, coinbase.BodyHash)
+	}
+}
+
+func TestInvAndGetDataRoundTrip(t *testing.T) {
+	hashes := [][]byte{[]byte("hash-one"), []byte("hash-two")}
+
+	wantInv := &InvMessage{Kind: InvKindTx, Hashes: hashes}
+	encodedInv, err := encodeInv(wantInv)
+	if err != nil {
+		t.Fatalf("encodeInv: %v", err)
+	}
+	gotInv, err := decodeInv(encodedInv)
+	if err != nil {
+		t.Fatalf("decodeInv: %v", err)
+	}
+	if gotInv.Kind != wantInv.Kind || len(gotInv.Hashes) != len(wantInv.Hashes) {
+		t.Fatalf("decodeInv() = %+v, want %+v", gotInv, wantInv)
+	}
+
+	wantGetData := &GetDataMessage{Kind: InvKindBlock, Hashes: hashes}
+	encodedGetData, err := encodeGetData(wantGetData)
+	if err != nil {
+		t.Fatalf("encodeGetData: %v", err)
+	}
+	gotGetData, err := decodeGetData(encodedGetData)
+	if err != nil {
+		t.Fatalf("decodeGetData: %v", err)
+	}
+	if gotGetData.Kind != wantGetData.Kind || len(gotGetData.Hashes) != len(wantGetData.Hashes) {
+		t.Fatalf("decodeGetData() = %+v, want %+v", gotGetData, wantGetData)
+	}
+}
+
+func TestPingPongRoundTrip(t *testing.T) {
+	encodedPing, err := encodePing(&PingMessage{Nonce: 123})
+	if err != nil {
+		t.Fatalf("encodePing: %v", err)
+	}
+	gotPing, err := decodePing(encodedPing)
+	if err != nil {
+		t.Fatalf("decodePing: %v", err)
+	}
+	if gotPing.Nonce != 123 {
+		t.Fatalf("decodePing().Nonce = %d, want 123", gotPing.Nonce)
+	}
+
+	encodedPong, err := encodePong(&PongMessage{Nonce: 456})
+	if err != nil {
+		t.Fatalf("encodePong: %v", err)
+	}
+	gotPong, err := decodePong(encodedPong)
+	if err != nil {
+		t.Fatalf("decodePong: %v", err)
+	}
+	if gotPong.Nonce != 456 {
+		t.Fatalf("decodePong().Nonce = %d, want 456", gotPong.Nonce)
+	}
+}
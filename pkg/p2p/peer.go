@@ -0,0 +1,159 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Peer wraps a single TCP connection to another node, serializing writes
+// so concurrent relays (mined blocks, mempool transactions, handshake
+// traffic) don't interleave their frames.
+type Peer struct {
+	Addr string
+
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+// newPeer wraps an already-dialed or already-accepted connection.
+func newPeer(conn net.Conn) *Peer {
+	return &Peer{
+		Addr: conn.RemoteAddr().String(),
+		conn: conn,
+	}
+}
+
+// send encodes and writes a single message frame.
+func (p *Peer) send(msgType MessageType, payload []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	return writeFrame(p.conn, msgType, payload)
+}
+
+// receive blocks until the next frame arrives and decodes it into the
+// corresponding message type. The returned value is one of
+// *VersionMessage, *VerackMessage, *GetHeadersMessage, *HeadersMessage,
+// *GetBlockMessage, *BlockMessage, *TxMessage, *InvMessage,
+// *GetDataMessage, *PingMessage, or *PongMessage.
+func (p *Peer) receive() (interface{}, error) {
+	msgType, payload, err := readFrame(p.conn)
+	if err != nil {
+		return nil, err
+	}
+	switch msgType {
+	case MessageTypeVersion:
+		return decodeVersion(payload)
+	case MessageTypeVerack:
+		return &VerackMessage{}, nil
+	case MessageTypeGetHeaders:
+		return decodeGetHeaders(payload)
+	case MessageTypeHeaders:
+		return decodeHeaders(payload)
+	case MessageTypeGetBlock:
+		return decodeGetBlock(payload)
+	case MessageTypeBlock:
+		return decodeBlock(payload)
+	case MessageTypeTx:
+		return decodeTx(payload)
+	case MessageTypeInv:
+		return decodeInv(payload)
+	case MessageTypeGetData:
+		return decodeGetData(payload)
+	case MessageTypePing:
+		return decodePing(payload)
+	case MessageTypePong:
+		return decodePong(payload)
+	default:
+		return nil, fmt.Errorf("p2p: peer %s sent unknown message type %d", p.Addr, msgType)
+	}
+}
+
+func (p *Peer) sendVersion(m *VersionMessage) error {
+	payload, err := encodeVersion(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeVersion, payload)
+}
+
+func (p *Peer) sendVerack() error {
+	return p.send(MessageTypeVerack, nil)
+}
+
+func (p *Peer) sendGetHeaders(m *GetHeadersMessage) error {
+	payload, err := encodeGetHeaders(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeGetHeaders, payload)
+}
+
+func (p *Peer) sendHeaders(m *HeadersMessage) error {
+	payload, err := encodeHeaders(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeHeaders, payload)
+}
+
+func (p *Peer) sendGetBlock(m *GetBlockMessage) error {
+	payload, err := encodeGetBlock(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeGetBlock, payload)
+}
+
+func (p *Peer) sendBlock(m *BlockMessage) error {
+	payload, err := encodeBlock(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeBlock, payload)
+}
+
+func (p *Peer) sendTx(m *TxMessage) error {
+	payload, err := encodeTx(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeTx, payload)
+}
+
+func (p *Peer) sendInv(m *InvMessage) error {
+	payload, err := encodeInv(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeInv, payload)
+}
+
+func (p *Peer) sendGetData(m *GetDataMessage) error {
+	payload, err := encodeGetData(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypeGetData, payload)
+}
+
+func (p *Peer) sendPing(m *PingMessage) error {
+	payload, err := encodePing(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypePing, payload)
+}
+
+func (p *Peer) sendPong(m *PongMessage) error {
+	payload, err := encodePong(m)
+	if err != nil {
+		return err
+	}
+	return p.send(MessageTypePong, payload)
+}
+
+// Close closes the underlying connection.
+func (p *Peer) Close() error {
+	return p.conn.Close()
+}
@@ -0,0 +1,384 @@
+package p2p
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+)
+
+// Server drives every Peer connection for one Node: it accepts inbound
+// connections, dials a seed list, performs the version handshake, syncs
+// the chain headers-first against whichever peer it dialed, and relays
+// newly mined blocks and mempool transactions to the rest of the
+// network. It satisfies core.BlockPublisher.
+type Server struct {
+	Node *core.Node
+	Pool *mempool.Mempool
+
+	listener net.Listener
+
+	mu    sync.Mutex
+	peers map[string]*Peer
+}
+
+// NewServer creates a Server driving node and pool, and registers itself
+// as node's BlockPublisher so every block node seals is announced to the
+// network.
+func NewServer(node *core.Node, pool *mempool.Mempool) *Server {
+	s := &Server{
+		Node:  node,
+		Pool:  pool,
+		peers: make(map[string]*Peer),
+	}
+	node.Publisher = s
+	return s
+}
+
+// Start listens on listenAddr for inbound peers and dials each address
+// in seeds in the background. It returns once the listener is up; seed
+// connections (including their initial header sync) continue
+// asynchronously.
+func (s *Server) Start(listenAddr string, seeds []string) error {
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("p2p: listen on %s: %w", listenAddr, err)
+	}
+	s.listener = ln
+	go s.acceptLoop()
+
+	for _, addr := range seeds {
+		addr := addr
+		go func() {
+			if err := s.Connect(addr); err != nil {
+				log.Printf("p2p: could not connect to seed %s: %v", addr, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Close stops accepting new connections and disconnects every peer.
+func (s *Server) Close() error {
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mu.Lock()
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, peer := range s.peers {
+		peers = append(peers, peer)
+	}
+	s.mu.Unlock()
+	for _, peer := range peers {
+		peer.Close()
+	}
+	return err
+}
+
+func (s *Server) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleInbound(conn)
+	}
+}
+
+func (s *Server) handleInbound(conn net.Conn) {
+	peer := newPeer(conn)
+	if _, err := s.handshake(peer); err != nil {
+		log.Printf("p2p: handshake with %s failed: %v", peer.Addr, err)
+		peer.Close()
+		return
+	}
+	s.addPeer(peer)
+	s.readLoop(peer)
+}
+
+// Connect dials addr, performs the version handshake, syncs the chain
+// headers-first against it, and then keeps the connection open for
+// ongoing relay. It blocks until the initial sync finishes (or fails);
+// callers that don't want to wait should run it in a goroutine, as
+// Start does for the seed list.
+func (s *Server) Connect(addr string) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("p2p: dial %s: %w", addr, err)
+	}
+	peer := newPeer(conn)
+	if _, err := s.handshake(peer); err != nil {
+		peer.Close()
+		return fmt.Errorf("p2p: handshake with %s: %w", addr, err)
+	}
+	s.addPeer(peer)
+
+	if err := s.syncHeaders(peer); err != nil {
+		log.Printf("p2p: syncing with %s: %v", peer.Addr, err)
+	}
+	go s.readLoop(peer)
+	return nil
+}
+
+func (s *Server) addPeer(peer *Peer) {
+	s.mu.Lock()
+	s.peers[peer.Addr] = peer
+	s.mu.Unlock()
+}
+
+func (s *Server) removePeer(peer *Peer) {
+	s.mu.Lock()
+	delete(s.peers, peer.Addr)
+	s.mu.Unlock()
+}
+
+// handshake exchanges VersionMessage and VerackMessage with peer. Both
+// sides run the same steps regardless of which one dialed, so it doesn't
+// matter whether peer is inbound or outbound.
+func (s *Server) handshake(peer *Peer) (*VersionMessage, error) {
+	ourVersion := &VersionMessage{
+		ProtocolVersion: ProtocolVersion,
+		BlockHeight:     s.Node.Chain.BlockHeight(),
+	}
+	if err := peer.sendVersion(ourVersion); err != nil {
+		return nil, err
+	}
+	msg, err := peer.receive()
+	if err != nil {
+		return nil, err
+	}
+	theirVersion, ok := msg.(*VersionMessage)
+	if !ok {
+		return nil, fmt.Errorf("p2p: expected version from %s, got %T", peer.Addr, msg)
+	}
+	if theirVersion.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("p2p: peer %s speaks protocol version %d, want %d", peer.Addr, theirVersion.ProtocolVersion, ProtocolVersion)
+	}
+	if err := peer.sendVerack(); err != nil {
+		return nil, err
+	}
+	msg, err = peer.receive()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.(*VerackMessage); !ok {
+		return nil, fmt.Errorf("p2p: expected verack from %s, got %T", peer.Addr, msg)
+	}
+	return theirVersion, nil
+}
+
+// syncHeaders requests blocks from peer in headerBatchCount-sized
+// batches, starting just after our own tip, and ingests each one. It
+// assumes peer's chain shares our current tip as a common prefix: a
+// fork that diverges at or before our tip will fail to ingest here and
+// has to be picked up later through ordinary gossip (handleBlock /
+// Chain.AcceptSideBlock) instead.
+func (s *Server) syncHeaders(peer *Peer) error {
+	for {
+		fromId := uint32(core.GenesisId)
+		if tip := s.Node.Chain.Tip(); tip != nil {
+			fromId = tip.Body.Id + 1
+		}
+		if err := peer.sendGetHeaders(&GetHeadersMessage{FromId: fromId}); err != nil {
+			return err
+		}
+		msg, err := peer.receive()
+		if err != nil {
+			return err
+		}
+		headers, ok := msg.(*HeadersMessage)
+		if !ok {
+			return fmt.Errorf("p2p: expected headers from %s, got %T", peer.Addr, msg)
+		}
+		for _, block := range headers.Blocks {
+			if err := s.ingestBlock(block); err != nil {
+				return fmt.Errorf("ingesting block from %s: %w", peer.Addr, err)
+			}
+		}
+		if len(headers.Blocks) < headerBatchCount {
+			return nil
+		}
+	}
+}
+
+// ingestBlock feeds block into the chain the same way a freshly received
+// Block message does: through the full verifier if it extends the tip,
+// or through AcceptSideBlock's cumulative-work comparison otherwise.
+func (s *Server) ingestBlock(block *core.Block) error {
+	return core.AcceptBlock(s.Node.Chain, block)
+}
+
+func (s *Server) readLoop(peer *Peer) {
+	defer func() {
+		s.removePeer(peer)
+		peer.Close()
+	}()
+	for {
+		msg, err := peer.receive()
+		if err != nil {
+			return
+		}
+		if err := s.handleMessage(peer, msg); err != nil {
+			log.Printf("p2p: handling %T from %s: %v", msg, peer.Addr, err)
+		}
+	}
+}
+
+func (s *Server) handleMessage(peer *Peer, msg interface{}) error {
+	switch m := msg.(type) {
+	case *GetHeadersMessage:
+		return s.handleGetHeaders(peer, m)
+	case *GetBlockMessage:
+		return s.handleGetBlock(peer, m)
+	case *BlockMessage:
+		return s.handleBlock(peer, m)
+	case *TxMessage:
+		return s.handleTx(peer, m)
+	case *InvMessage:
+		return s.handleInv(peer, m)
+	case *GetDataMessage:
+		return s.handleGetData(peer, m)
+	case *PingMessage:
+		return peer.sendPong(&PongMessage{Nonce: m.Nonce})
+	case *PongMessage:
+		return nil
+	default:
+		return fmt.Errorf("p2p: unexpected %T after handshake", m)
+	}
+}
+
+func (s *Server) handleGetHeaders(peer *Peer, m *GetHeadersMessage) error {
+	blocks := make([]*core.Block, 0, headerBatchCount)
+	for id := m.FromId; len(blocks) < headerBatchCount; id++ {
+		block, err := s.Node.Chain.GetBlockByID(id)
+		if err != nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	return peer.sendHeaders(&HeadersMessage{Blocks: blocks})
+}
+
+func (s *Server) handleGetBlock(peer *Peer, m *GetBlockMessage) error {
+	block, err := s.Node.Chain.GetBlockByHash(m.Hash)
+	if err != nil {
+		return err
+	}
+	return peer.sendBlock(&BlockMessage{Block: block})
+}
+
+func (s *Server) handleBlock(peer *Peer, m *BlockMessage) error {
+	if err := s.ingestBlock(m.Block); err != nil {
+		return err
+	}
+	s.broadcastInv(InvKindBlock, m.Block.BodyHash, peer)
+	return nil
+}
+
+func (s *Server) handleTx(peer *Peer, m *TxMessage) error {
+	err := s.Pool.Add(s.Node.Chain, m.Transaction)
+	if err == mempool.ErrAlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.broadcastInv(InvKindTx, m.Transaction.BodyHash, peer)
+	return nil
+}
+
+func (s *Server) handleInv(peer *Peer, m *InvMessage) error {
+	var unknown [][]byte
+	for _, hash := range m.Hashes {
+		switch m.Kind {
+		case InvKindBlock:
+			if _, err := s.Node.Chain.GetBlockByHash(hash); err != nil {
+				unknown = append(unknown, hash)
+			}
+		case InvKindTx:
+			if _, err := s.Node.Chain.GetTransaction(hash); err != nil {
+				unknown = append(unknown, hash)
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	return peer.sendGetData(&GetDataMessage{Kind: m.Kind, Hashes: unknown})
+}
+
+func (s *Server) handleGetData(peer *Peer, m *GetDataMessage) error {
+	for _, hash := range m.Hashes {
+		switch m.Kind {
+		case InvKindBlock:
+			block, err := s.Node.Chain.GetBlockByHash(hash)
+			if err != nil {
+				continue
+			}
+			if err := peer.sendBlock(&BlockMessage{Block: block}); err != nil {
+				return err
+			}
+		case InvKindTx:
+			transaction, err := s.Node.Chain.GetTransaction(hash)
+			if err != nil {
+				continue
+			}
+			if err := peer.sendTx(&TxMessage{Transaction: transaction}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// broadcastInv announces hash to every connected peer except exclude
+// (which is nil when the announcement originates locally, e.g. from
+// PublishBlock).
+func (s *Server) broadcastInv(kind InvKind, hash []byte, exclude *Peer) {
+	s.mu.Lock()
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, peer := range s.peers {
+		if peer != exclude {
+			peers = append(peers, peer)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, peer := range peers {
+		if err := peer.sendInv(&InvMessage{Kind: kind, Hashes: [][]byte{hash}}); err != nil {
+			log.Printf("p2p: relaying inv to %s: %v", peer.Addr, err)
+		}
+	}
+}
+
+// PublishBlock implements core.BlockPublisher: it announces a block that
+// Node.Mine just sealed to every connected peer.
+func (s *Server) PublishBlock(block *core.Block) {
+	s.broadcastInv(InvKindBlock, block.BodyHash, nil)
+}
+
+// PublishTx announces a transaction accepted directly into the local
+// mempool (rather than received from a peer) to every connected peer.
+// A caller that adds a transaction to the pool itself, such as a future
+// RPC endpoint taking client-submitted transactions, should call this
+// afterward so the transaction propagates.
+func (s *Server) PublishTx(tx *core.Transaction) {
+	s.broadcastInv(InvKindTx, tx.BodyHash, nil)
+}
+
+// PeerCount returns the number of currently connected peers.
+func (s *Server) PeerCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
+
+// Addr returns the address Start bound its listener to, useful when
+// Start was given port 0 and the operating system picked one.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
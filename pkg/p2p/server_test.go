@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+)
+
+// newTestServer builds a Server over a fresh in-memory chain, listening
+// on an OS-assigned loopback port.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	chain, err := core.NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := core.NewNode(chain)
+	server := NewServer(node, mempool.New())
+	if err := server.Start("127.0.0.1:0", nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func minePubKeyHash(t *testing.T) []byte {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return core.PubKeyHash(&priv.PublicKey)
+}
+
+// waitForHeight polls chain's height until it reaches want or the
+// deadline passes, so the test doesn't race the background goroutines
+// that carry out a connection's handshake, sync, and relay.
+func waitForHeight(t *testing.T, chain *core.Chain, want uint32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if chain.BlockHeight() == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("BlockHeight() = %d after waiting, want %d", chain.BlockHeight(), want)
+}
+
+func TestConnectSyncsExistingChain(t *testing.T) {
+	serverA := newTestServer(t)
+	genesis, err := serverA.Node.Mine(serverA.Pool, 0, minePubKeyHash(t))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	serverB := newTestServer(t)
+	if err := serverB.Connect(serverA.Addr()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	if got := serverB.Node.Chain.Tip(); got == nil || string(got.BodyHash) != string(genesis.BodyHash) {
+		t.Fatalf("B's tip = %v after sync, want genesis %x", got, genesis.BodyHash)
+	}
+}
+
+func TestMineRelaysBlockToConnectedPeer(t *testing.T) {
+	serverA := newTestServer(t)
+	serverB := newTestServer(t)
+	if err := serverB.Connect(serverA.Addr()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	block, err := serverA.Node.Mine(serverA.Pool, 0, minePubKeyHash(t))
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	waitForHeight(t, serverB.Node.Chain, block.Body.Id+1)
+	if got := serverB.Node.Chain.Tip(); string(got.BodyHash) != string(block.BodyHash) {
+		t.Fatalf("B's tip hash = %x after relay, want %x", got.BodyHash, block.BodyHash)
+	}
+}
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+	"github.com/albert-zhong/go-coin/pkg/wallet"
+)
+
+// maxRawPayloadSize bounds the decoded length of a sendrawtransaction or
+// submitblock payload, the same way pkg/p2p's maxPayloadSize bounds a wire
+// message: these two methods are this node's other integration point for
+// attacker-controlled bytes reaching core.DeserializeTransaction/
+// DeserializeBlock, so they get the same cap rather than trusting the
+// codec's own bounds alone.
+const maxRawPayloadSize = 32 << 20 // 32 MiB
+
+// getBlockCount returns the number of blocks in the chain, the same
+// quantity Chain.BlockHeight reports.
+func (s *Server) getBlockCount(json.RawMessage) (interface{}, error) {
+	return s.Chain.BlockHeight(), nil
+}
+
+// getBlockHash returns the hash, as hex, of the block at the given
+// height. Params: [height].
+func (s *Server) getBlockHash(params json.RawMessage) (interface{}, error) {
+	var height uint32
+	if err := unmarshalParams(params, &height); err != nil {
+		return nil, err
+	}
+	block, err := s.Chain.GetBlockByID(height)
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(block.BodyHash), nil
+}
+
+// getBlock returns the block with the given hash, hex-encoded. Params:
+// [hash].
+func (s *Server) getBlock(params json.RawMessage) (interface{}, error) {
+	var hashHex string
+	if err := unmarshalParams(params, &hashHex); err != nil {
+		return nil, err
+	}
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: hash is not valid hex: %w", err)}
+	}
+	return s.Chain.GetBlockByHash(hash)
+}
+
+// getRawTransaction returns the transaction with the given hash, hex-encoded.
+// Params: [hash].
+func (s *Server) getRawTransaction(params json.RawMessage) (interface{}, error) {
+	var hashHex string
+	if err := unmarshalParams(params, &hashHex); err != nil {
+		return nil, err
+	}
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: hash is not valid hex: %w", err)}
+	}
+	return s.Chain.GetTransaction(hash)
+}
+
+// sendRawTransaction decodes a hex-encoded, serialized Transaction
+// (Transaction.Serialize's wire form), adds it to the mempool, announces
+// it via Publisher if one is set, and returns its hash as hex. Params:
+// [rawTransactionHex].
+func (s *Server) sendRawTransaction(params json.RawMessage) (interface{}, error) {
+	var rawHex string
+	if err := unmarshalParams(params, &rawHex); err != nil {
+		return nil, err
+	}
+	if len(rawHex) > 2*maxRawPayloadSize {
+		return nil, &paramsError{fmt.Errorf("rpc: rawtx is %d hex chars, over the %d byte limit", len(rawHex), maxRawPayloadSize)}
+	}
+	raw, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: rawtx is not valid hex: %w", err)}
+	}
+	transaction, err := core.DeserializeTransaction(raw)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: rawtx does not decode to a transaction: %w", err)}
+	}
+	if err := s.Pool.Add(s.Chain, transaction); err != nil && err != mempool.ErrAlreadyExists {
+		return nil, err
+	}
+	if s.Publisher != nil {
+		s.Publisher.PublishTx(transaction)
+	}
+	return hex.EncodeToString(transaction.BodyHash), nil
+}
+
+// getBalance sums the value of every unspent transaction owned by
+// address, per Chain.GetBalance. Params: [address].
+func (s *Server) getBalance(params json.RawMessage) (interface{}, error) {
+	var address string
+	if err := unmarshalParams(params, &address); err != nil {
+		return nil, err
+	}
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(address)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: address is not valid: %w", err)}
+	}
+	return s.Chain.GetBalance(pubKeyHash)
+}
+
+// getMempoolInfo reports the number of transactions currently pending.
+func (s *Server) getMempoolInfo(json.RawMessage) (interface{}, error) {
+	return struct {
+		Size int `json:"size"`
+	}{Size: s.Pool.Len()}, nil
+}
+
+// submitBlock decodes a hex-encoded, serialized Block
+// (Block.Serialize's wire form) and feeds it into the chain via
+// core.AcceptBlock, the same entry point the p2p package's inbound block
+// handler uses. It announces an accepted block via Publisher if one is
+// set. Params: [rawBlockHex].
+func (s *Server) submitBlock(params json.RawMessage) (interface{}, error) {
+	var rawHex string
+	if err := unmarshalParams(params, &rawHex); err != nil {
+		return nil, err
+	}
+	if len(rawHex) > 2*maxRawPayloadSize {
+		return nil, &paramsError{fmt.Errorf("rpc: rawblock is %d hex chars, over the %d byte limit", len(rawHex), maxRawPayloadSize)}
+	}
+	raw, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: rawblock is not valid hex: %w", err)}
+	}
+	block, err := core.DeserializeBlock(raw)
+	if err != nil {
+		return nil, &paramsError{fmt.Errorf("rpc: rawblock does not decode to a block: %w", err)}
+	}
+	if err := core.AcceptBlock(s.Chain, block); err != nil {
+		return nil, err
+	}
+
+	if s.Publisher != nil {
+		s.Publisher.PublishBlock(block)
+	}
+	return nil, nil
+}
@@ -0,0 +1,161 @@
+// Package rpc exposes a chain and mempool over JSON-RPC 2.0 (see
+// https://www.jsonrpc.org/specification) on a single HTTP endpoint,
+// following the shape of Bitcoin Core's RPC API: getblockcount,
+// getblockhash, getblock, getrawtransaction, sendrawtransaction,
+// getbalance, getmempoolinfo, and submitblock. An OpenAPI description of
+// the same methods is served at /swagger.
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	errCodeParse          = -32700
+	errCodeInvalidParams  = -32602
+	errCodeMethodNotFound = -32601
+	errCodeInternal       = -32603
+)
+
+// Publisher lets the rpc Server announce client-submitted blocks and
+// transactions to the network. *p2p.Server satisfies this interface;
+// it's expressed here rather than imported, the same way core.Node keeps
+// its own BlockPublisher interface instead of importing p2p.
+type Publisher interface {
+	PublishBlock(block *core.Block)
+	PublishTx(tx *core.Transaction)
+}
+
+// Server answers JSON-RPC requests against Chain and Pool.
+type Server struct {
+	Chain *core.Chain
+	Pool  *mempool.Mempool
+
+	// Publisher, if set, is notified of blocks and transactions accepted
+	// through submitblock and sendrawtransaction so they propagate to the
+	// network. It's left nil by NewServer; a caller wiring up the p2p
+	// package sets it afterward, the same way p2p.NewServer sets
+	// core.Node.Publisher.
+	Publisher Publisher
+
+	methods map[string]func(json.RawMessage) (interface{}, error)
+}
+
+// NewServer creates a Server answering JSON-RPC requests against chain
+// and pool.
+func NewServer(chain *core.Chain, pool *mempool.Mempool) *Server {
+	s := &Server{
+		Chain: chain,
+		Pool:  pool,
+	}
+	s.methods = map[string]func(json.RawMessage) (interface{}, error){
+		"getblockcount":      s.getBlockCount,
+		"getblockhash":       s.getBlockHash,
+		"getblock":           s.getBlock,
+		"getrawtransaction":  s.getRawTransaction,
+		"sendrawtransaction": s.sendRawTransaction,
+		"getbalance":         s.getBalance,
+		"getmempoolinfo":     s.getMempoolInfo,
+		"submitblock":        s.submitBlock,
+	}
+	return s
+}
+
+// request is a single JSON-RPC 2.0 call.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// response is a single JSON-RPC 2.0 reply. Result and Error are mutually
+// exclusive, per the spec.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handler returns an http.Handler serving JSON-RPC requests at "/" and
+// the embedded OpenAPI spec at "/swagger".
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveRPC)
+	mux.HandleFunc("/swagger", serveSwagger)
+	return mux
+}
+
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "rpc: only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResponse(w, response{JSONRPC: "2.0", Error: &rpcError{Code: errCodeParse, Message: err.Error()}})
+		return
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		writeResponse(w, response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: errCodeMethodNotFound, Message: fmt.Sprintf("rpc: unknown method %q", req.Method)}})
+		return
+	}
+	result, err := method(req.Params)
+	if err != nil {
+		code := errCodeInternal
+		if _, ok := err.(*paramsError); ok {
+			code = errCodeInvalidParams
+		}
+		writeResponse(w, response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: code, Message: err.Error()}})
+		return
+	}
+	writeResponse(w, response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func writeResponse(w http.ResponseWriter, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	// A JSON-RPC error is still a successful HTTP exchange; the error
+	// lives in the body, not the status line.
+	json.NewEncoder(w).Encode(resp)
+}
+
+// unmarshalParams decodes a JSON-RPC "params" array into args, one
+// element per arg, in order. It requires the array to have exactly
+// len(args) elements rather than silently zero-filling a short one, so a
+// caller that forgets a parameter gets an invalid-params error instead of
+// having the missing value default to its zero value.
+func unmarshalParams(params json.RawMessage, args ...interface{}) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return &paramsError{fmt.Errorf("rpc: params must be a JSON array: %w", err)}
+	}
+	if len(raw) != len(args) {
+		return &paramsError{fmt.Errorf("rpc: expected %d params, got %d", len(args), len(raw))}
+	}
+	for i, arg := range args {
+		if err := json.Unmarshal(raw[i], arg); err != nil {
+			return &paramsError{fmt.Errorf("rpc: param %d: %w", i, err)}
+		}
+	}
+	return nil
+}
+
+// paramsError marks an error as an invalid-params failure so serveRPC
+// reports errCodeInvalidParams instead of errCodeInternal.
+type paramsError struct{ err error }
+
+func (e *paramsError) Error() string { return e.err.Error() }
@@ -0,0 +1,273 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+	"github.com/albert-zhong/go-coin/pkg/mempool"
+	"github.com/albert-zhong/go-coin/pkg/wallet"
+)
+
+// newTestServer mines a genesis block paying minerAddress and returns an
+// httptest.Server fronting a Server over that chain.
+func newTestServer(t *testing.T) (*httptest.Server, *Server, *core.Block, *wallet.Wallet) {
+	t.Helper()
+	chain, err := core.NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	node := core.NewNode(chain)
+	pool := mempool.New()
+
+	miner, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+	genesis, err := node.Mine(pool, 0, miner.PubKeyHash())
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+
+	rpcServer := NewServer(chain, pool)
+	httpServer := httptest.NewServer(rpcServer.Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer, rpcServer, genesis, miner
+}
+
+// call POSTs a JSON-RPC request for method with params and decodes the
+// result into result (if non-nil), failing the test on a JSON-RPC error.
+func call(t *testing.T, url, method string, params interface{}, result interface{}) {
+	t.Helper()
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	req := request{JSONRPC: "2.0", Method: method, Params: rawParams, ID: json.RawMessage("1")}
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	var rpcResp response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rpcResp.Error != nil {
+		t.Fatalf("%s returned error: %+v", method, rpcResp.Error)
+	}
+	if result == nil {
+		return
+	}
+	resultBytes, err := json.Marshal(rpcResp.Result)
+	if err != nil {
+		t.Fatalf("re-marshal result: %v", err)
+	}
+	if err := json.Unmarshal(resultBytes, result); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+}
+
+func TestGetBlockCount(t *testing.T) {
+	httpServer, _, _, _ := newTestServer(t)
+	var count uint32
+	call(t, httpServer.URL, "getblockcount", []interface{}{}, &count)
+	if count != 1 {
+		t.Fatalf("getblockcount = %d, want 1", count)
+	}
+}
+
+func TestGetBlockHashAndGetBlock(t *testing.T) {
+	httpServer, _, genesis, _ := newTestServer(t)
+
+	var hash string
+	call(t, httpServer.URL, "getblockhash", []interface{}{0}, &hash)
+	if hash != hex.EncodeToString(genesis.BodyHash) {
+		t.Fatalf("getblockhash = %s, want %x", hash, genesis.BodyHash)
+	}
+
+	var block struct {
+		Hash string `json:"hash"`
+		Id   uint32 `json:"id"`
+	}
+	call(t, httpServer.URL, "getblock", []interface{}{hash}, &block)
+	if block.Hash != hash || block.Id != 0 {
+		t.Fatalf("getblock = %+v, want hash %s id 0", block, hash)
+	}
+}
+
+func TestGetRawTransaction(t *testing.T) {
+	httpServer, _, genesis, _ := newTestServer(t)
+	coinbase := genesis.Body.Transactions[0]
+
+	var tx struct {
+		Hash       string `json:"hash"`
+		IsCoinbase bool   `json:"isCoinbase"`
+	}
+	call(t, httpServer.URL, "getrawtransaction", []interface{}{hex.EncodeToString(coinbase.BodyHash)}, &tx)
+	if tx.Hash != hex.EncodeToString(coinbase.BodyHash) || !tx.IsCoinbase {
+		t.Fatalf("getrawtransaction = %+v, want hash %x isCoinbase true", tx, coinbase.BodyHash)
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	httpServer, _, _, miner := newTestServer(t)
+
+	var balance uint32
+	call(t, httpServer.URL, "getbalance", []interface{}{miner.Address()}, &balance)
+	if balance != core.MiningReward {
+		t.Fatalf("getbalance = %d, want %d", balance, core.MiningReward)
+	}
+}
+
+func TestSendRawTransactionAddsToMempool(t *testing.T) {
+	httpServer, rpcServer, genesis, miner := newTestServer(t)
+	coinbase := genesis.Body.Transactions[0]
+
+	bob, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+	transaction, err := core.NewTransaction(
+		&core.TransactionBody{
+			InputTransactionHashes: [][]byte{coinbase.BodyHash},
+			NextOwner:              bob.PubKeyHash(),
+			InputValue:             core.MiningReward,
+		},
+		[]*ecdsa.PrivateKey{miner.PrivateKey},
+		false,
+	)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	raw, err := transaction.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+
+	var hash string
+	call(t, httpServer.URL, "sendrawtransaction", []interface{}{hex.EncodeToString(raw)}, &hash)
+	if hash != hex.EncodeToString(transaction.BodyHash) {
+		t.Fatalf("sendrawtransaction = %s, want %x", hash, transaction.BodyHash)
+	}
+	if rpcServer.Pool.Len() != 1 {
+		t.Fatalf("Pool.Len() = %d after sendrawtransaction, want 1", rpcServer.Pool.Len())
+	}
+}
+
+// TestSendRawTransactionRejectsBogusLengthPrefix reproduces sending a tiny
+// payload that claims a huge input-transaction-hash count: it should decode
+// to an invalid-params error instead of the decoder trying to preallocate
+// a slice sized off that bogus count.
+func TestSendRawTransactionRejectsBogusLengthPrefix(t *testing.T) {
+	httpServer, _, _, _ := newTestServer(t)
+
+	raw := []byte{0xf0, 0xff, 0xff, 0xff} // a uint32 count of 0xFFFFFFF0, no payload behind it
+	req := request{JSONRPC: "2.0", Method: "sendrawtransaction", Params: mustMarshal(t, []interface{}{hex.EncodeToString(raw)}), ID: json.RawMessage("1")}
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpServer.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST sendrawtransaction: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var rpcResp response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rpcResp.Error == nil {
+		t.Fatal("sendrawtransaction with a bogus length prefix succeeded, want error")
+	}
+}
+
+// mustMarshal marshals v, failing the test on error.
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return raw
+}
+
+func TestGetMempoolInfo(t *testing.T) {
+	httpServer, rpcServer, genesis, miner := newTestServer(t)
+	coinbase := genesis.Body.Transactions[0]
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	transaction, err := core.NewTransaction(
+		&core.TransactionBody{
+			InputTransactionHashes: [][]byte{coinbase.BodyHash},
+			NextOwner:              core.PubKeyHash(&priv.PublicKey),
+			InputValue:             core.MiningReward,
+		},
+		[]*ecdsa.PrivateKey{miner.PrivateKey},
+		false,
+	)
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	if err := rpcServer.Pool.Add(rpcServer.Chain, transaction); err != nil {
+		t.Fatalf("Pool.Add: %v", err)
+	}
+
+	var info struct {
+		Size int `json:"size"`
+	}
+	call(t, httpServer.URL, "getmempoolinfo", []interface{}{}, &info)
+	if info.Size != 1 {
+		t.Fatalf("getmempoolinfo.size = %d, want 1", info.Size)
+	}
+}
+
+// TestSubmitBlockExtendsTip mines a second block against an independent
+// chain that shares the rpc Server's genesis block, then submits it
+// through submitblock and checks it lands on the rpc Server's chain.
+func TestSubmitBlockExtendsTip(t *testing.T) {
+	httpServer, rpcServer, genesis, miner := newTestServer(t)
+
+	sourceChain, err := core.NewChain(nil)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	if err := sourceChain.Append(genesis); err != nil {
+		t.Fatalf("Append genesis: %v", err)
+	}
+	sourceNode := core.NewNode(sourceChain)
+	next, err := sourceNode.Mine(mempool.New(), 0, miner.PubKeyHash())
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	raw, err := next.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+
+	call(t, httpServer.URL, "submitblock", []interface{}{hex.EncodeToString(raw)}, nil)
+	if rpcServer.Chain.BlockHeight() != 2 {
+		t.Fatalf("BlockHeight() = %d after submitblock, want 2", rpcServer.Chain.BlockHeight())
+	}
+	if string(rpcServer.Chain.Tip().BodyHash) != string(next.BodyHash) {
+		t.Fatalf("Tip() = %x after submitblock, want %x", rpcServer.Chain.Tip().BodyHash, next.BodyHash)
+	}
+}
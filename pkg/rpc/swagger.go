@@ -0,0 +1,18 @@
+package rpc
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// swaggerSpec is the OpenAPI description of this package's JSON-RPC
+// methods, baked into the binary so /swagger works without shipping a
+// separate file alongside it.
+//
+//go:embed swagger.yaml
+var swaggerSpec []byte
+
+func serveSwagger(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(swaggerSpec)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+
+	"go.etcd.io/bbolt"
+)
+
+// bucketName is the single bucket every key lives in. Keyspace
+// partitioning is already handled by the prefix bytes in storage.go's
+// XKey constructors, so BoltStore doesn't need bbolt's own buckets for
+// that; one bucket keeps Get/Put/Delete/Seek a thin pass-through.
+var bucketName = []byte("go-coin")
+
+// BoltStore is a durable, single-file on-disk Store backed by BoltDB, so
+// a Chain can survive an actual process restart rather than losing all
+// state the moment it exits, as MemoryStore does.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB database at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Get(key []byte) ([]byte, error) {
+	var out []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(bucketName).Get(key)
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		out = make([]byte, len(v))
+		copy(out, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *BoltStore) Put(key, value []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Put(key, value)
+	})
+}
+
+func (s *BoltStore) Delete(key []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Delete(key)
+	})
+}
+
+func (s *BoltStore) Seek(prefix []byte, fn func(key, value []byte) bool) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			keyCopy := make([]byte, len(k))
+			copy(keyCopy, k)
+			valueCopy := make([]byte, len(v))
+			copy(valueCopy, v)
+			if !fn(keyCopy, valueCopy) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
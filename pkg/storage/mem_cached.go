@@ -0,0 +1,127 @@
+package storage
+
+import "sync"
+
+// MemCachedStore sits in front of a persistent Store and buffers writes in
+// memory, mirroring neo-go's Blockchain memStore/Store split: block
+// processing writes go to the cache first so hot-path mining never blocks
+// on disk I/O, and Persist flushes the accumulated writes to the
+// underlying backend in one pass.
+type MemCachedStore struct {
+	mu      sync.Mutex
+	ps      Store
+	mem     map[string][]byte
+	deleted map[string]struct{}
+}
+
+// NewMemCachedStore wraps ps with an in-memory write buffer.
+func NewMemCachedStore(ps Store) *MemCachedStore {
+	return &MemCachedStore{
+		ps:      ps,
+		mem:     make(map[string][]byte),
+		deleted: make(map[string]struct{}),
+	}
+}
+
+func (s *MemCachedStore) Get(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deleted[string(key)]; ok {
+		return nil, ErrKeyNotFound
+	}
+	if v, ok := s.mem[string(key)]; ok {
+		out := make([]byte, len(v))
+		copy(out, v)
+		return out, nil
+	}
+	return s.ps.Get(key)
+}
+
+func (s *MemCachedStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := make([]byte, len(value))
+	copy(v, value)
+	s.mem[string(key)] = v
+	delete(s.deleted, string(key))
+	return nil
+}
+
+func (s *MemCachedStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.mem, string(key))
+	s.deleted[string(key)] = struct{}{}
+	return nil
+}
+
+// Seek sees the merged view of the write buffer over the persistent
+// store, so callers observe buffered writes before the next Persist.
+func (s *MemCachedStore) Seek(prefix []byte, fn func(key, value []byte) bool) error {
+	s.mu.Lock()
+	seen := make(map[string]struct{}, len(s.mem))
+	for k, v := range s.mem {
+		if !hasPrefix(k, prefix) {
+			continue
+		}
+		seen[k] = struct{}{}
+		if !fn([]byte(k), v) {
+			s.mu.Unlock()
+			return nil
+		}
+	}
+	deleted := make(map[string]struct{}, len(s.deleted))
+	for k := range s.deleted {
+		deleted[k] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	return s.ps.Seek(prefix, func(key, value []byte) bool {
+		k := string(key)
+		if _, ok := seen[k]; ok {
+			return true
+		}
+		if _, ok := deleted[k]; ok {
+			return true
+		}
+		return fn(key, value)
+	})
+}
+
+// Persist flushes all buffered writes and deletes to the underlying Store
+// and clears the write buffer. It returns the number of keys flushed.
+func (s *MemCachedStore) Persist() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flushed := 0
+	for k, v := range s.mem {
+		if err := s.ps.Put([]byte(k), v); err != nil {
+			return flushed, err
+		}
+		flushed++
+	}
+	for k := range s.deleted {
+		if err := s.ps.Delete([]byte(k)); err != nil {
+			return flushed, err
+		}
+		flushed++
+	}
+	s.mem = make(map[string][]byte)
+	s.deleted = make(map[string]struct{})
+	return flushed, nil
+}
+
+func (s *MemCachedStore) Close() error {
+	if _, err := s.Persist(); err != nil {
+		return err
+	}
+	return s.ps.Close()
+}
+
+func hasPrefix(s string, prefix []byte) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	return s[:len(prefix)] == string(prefix)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import "testing"
+
+func TestMemCachedStorePersist(t *testing.T) {
+	backing := NewMemoryStore()
+	cached := NewMemCachedStore(backing)
+
+	if err := cached.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Writes are buffered: the backing store hasn't seen them yet.
+	if _, err := backing.Get([]byte("a")); err != ErrKeyNotFound {
+		t.Fatalf("backing.Get before Persist: got err %v, want ErrKeyNotFound", err)
+	}
+	if v, err := cached.Get([]byte("a")); err != nil || string(v) != "1" {
+		t.Fatalf("cached.Get before Persist: got %q, %v, want %q, nil", v, err, "1")
+	}
+
+	n, err := cached.Persist()
+	if err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Persist flushed %d keys, want 1", n)
+	}
+	if v, err := backing.Get([]byte("a")); err != nil || string(v) != "1" {
+		t.Fatalf("backing.Get after Persist: got %q, %v, want %q, nil", v, err, "1")
+	}
+}
+
+func TestMemCachedStoreDelete(t *testing.T) {
+	backing := NewMemoryStore()
+	if err := backing.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("backing.Put: %v", err)
+	}
+	cached := NewMemCachedStore(backing)
+
+	if err := cached.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := cached.Get([]byte("a")); err != ErrKeyNotFound {
+		t.Fatalf("cached.Get after Delete: got err %v, want ErrKeyNotFound", err)
+	}
+	if _, err := backing.Get([]byte("a")); err != nil {
+		t.Fatalf("backing.Get before Persist should be untouched: %v", err)
+	}
+
+	if _, err := cached.Persist(); err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+	if _, err := backing.Get([]byte("a")); err != ErrKeyNotFound {
+		t.Fatalf("backing.Get after Persist: got err %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestMemCachedStoreSeekMergesBuffer(t *testing.T) {
+	backing := NewMemoryStore()
+	if err := backing.Put(BlockKey([]byte("old")), []byte("v-old")); err != nil {
+		t.Fatalf("backing.Put: %v", err)
+	}
+	cached := NewMemCachedStore(backing)
+	if err := cached.Put(BlockKey([]byte("new")), []byte("v-new")); err != nil {
+		t.Fatalf("cached.Put: %v", err)
+	}
+
+	seen := make(map[string]string)
+	err := cached.Seek([]byte{blockPrefix}, func(key, value []byte) bool {
+		seen[string(key)] = string(value)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Seek saw %d keys, want 2: %v", len(seen), seen)
+	}
+}
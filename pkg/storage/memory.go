@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MemoryStore is a volatile, map-backed Store. It is the default backend
+// for chains that don't need to survive a restart, and a drop-in stand-in
+// for an on-disk backend in tests.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	out := make([]byte, len(v))
+	copy(out, v)
+	return out, nil
+}
+
+func (s *MemoryStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := make([]byte, len(value))
+	copy(v, value)
+	s.data[string(key)] = v
+	return nil
+}
+
+func (s *MemoryStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, string(key))
+	return nil
+}
+
+func (s *MemoryStore) Seek(prefix []byte, fn func(key, value []byte) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, v := range s.data {
+		if !bytes.HasPrefix([]byte(k), prefix) {
+			continue
+		}
+		if !fn([]byte(k), v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package storage provides the pluggable key-value persistence layer used
+// by the chain to durably record headers, block bodies, and the
+// transaction index. It is intentionally modeled after neo-go's
+// pkg/core/storage: a small Store interface that concrete backends
+// implement, plus a MemCachedStore that buffers writes in memory and
+// flushes them to a backend on demand.
+package storage
+
+import "errors"
+
+// ErrKeyNotFound is returned by Get when no value is stored for a key.
+var ErrKeyNotFound = errors.New("storage: key not found")
+
+// Store is a durable key-value backend. Implementations include
+// MemoryStore (volatile, for tests and the default in-process chain) and
+// any on-disk backend (BoltDB, LevelDB, ...) that satisfies this
+// interface.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	// Seek calls fn for every stored key with the given prefix, in
+	// unspecified order, until fn returns false or no keys remain.
+	Seek(prefix []byte, fn func(key, value []byte) bool) error
+	Close() error
+}
+
+// Key prefixes partition the keyspace so headers, bodies, and the
+// transaction index never collide.
+const (
+	heightPrefix    byte = 0x00
+	headerPrefix    byte = 0x01
+	blockPrefix     byte = 0x02
+	txPrefix        byte = 0x03
+	workPrefix      byte = 0x04
+	blockWorkPrefix byte = 0x05
+	spentPrefix     byte = 0x06
+)
+
+// HeightKey returns the storage key for the chain's persisted block
+// height.
+func HeightKey() []byte {
+	return []byte{heightPrefix}
+}
+
+// HeaderKey returns the storage key for the header of block id.
+func HeaderKey(id uint32) []byte {
+	return appendUint32(headerPrefix, id)
+}
+
+// BlockKey returns the storage key for the body of the block with the
+// given hash.
+func BlockKey(hash []byte) []byte {
+	return append([]byte{blockPrefix}, hash...)
+}
+
+// TxKey returns the storage key for the transaction with the given hash.
+func TxKey(hash []byte) []byte {
+	return append([]byte{txPrefix}, hash...)
+}
+
+// TxKeyPrefix returns the shared prefix of every TxKey, for passing to
+// Seek when scanning the whole transaction index (e.g. to compute a
+// balance).
+func TxKeyPrefix() []byte {
+	return []byte{txPrefix}
+}
+
+// WorkKey returns the storage key for the chain's persisted cumulative
+// proof-of-work.
+func WorkKey() []byte {
+	return []byte{workPrefix}
+}
+
+// BlockWorkKey returns the storage key for the cumulative proof-of-work
+// of the chain up to and including the block with the given hash, used
+// to find how much work backs a fork's common ancestor during a reorg.
+func BlockWorkKey(hash []byte) []byte {
+	return append([]byte{blockWorkPrefix}, hash...)
+}
+
+// SpentKey returns the storage key marking that the transaction with the
+// given hash has been consumed as an input by some block on the main
+// chain, so a later block can't spend it again.
+func SpentKey(hash []byte) []byte {
+	return append([]byte{spentPrefix}, hash...)
+}
+
+func appendUint32(prefix byte, v uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = prefix
+	key[1] = byte(v >> 24)
+	key[2] = byte(v >> 16)
+	key[3] = byte(v >> 8)
+	key[4] = byte(v)
+	return key
+}
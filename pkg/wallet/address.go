@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/albert-zhong/go-coin/pkg/core"
+)
+
+const (
+	// addressVersion is the one-byte version prefix baked into every
+	// address, the same role Bitcoin's version byte plays.
+	addressVersion byte = 0x00
+	// checksumLen is the number of double-SHA256 bytes appended to an
+	// address so a typo can be detected before a transaction is built
+	// against it.
+	checksumLen = 4
+)
+
+// ErrInvalidAddress is returned when an address fails checksum
+// validation.
+var ErrInvalidAddress = errors.New("wallet: invalid address")
+
+// AddressFromPubKeyHash base58check-encodes pubKeyHash: a version byte,
+// the hash itself, and a 4-byte double-SHA256 checksum.
+func AddressFromPubKeyHash(pubKeyHash []byte) string {
+	versionedHash := append([]byte{addressVersion}, pubKeyHash...)
+	payload := append(versionedHash, checksum(versionedHash)...)
+	return Base58Encode(payload)
+}
+
+// PubKeyHashFromAddress validates address and returns the pubKeyHash
+// encoded within it.
+func PubKeyHashFromAddress(address string) ([]byte, error) {
+	payload, err := Base58Decode(address)
+	if err != nil {
+		return nil, err
+	}
+	if !validPayload(payload) {
+		return nil, ErrInvalidAddress
+	}
+	return payload[1 : len(payload)-checksumLen], nil
+}
+
+// ValidateAddress reports whether address is well-formed: valid base58
+// with a checksum that matches its payload.
+func ValidateAddress(address string) bool {
+	payload, err := Base58Decode(address)
+	if err != nil {
+		return false
+	}
+	return validPayload(payload)
+}
+
+func validPayload(payload []byte) bool {
+	if len(payload) <= checksumLen+1 {
+		return false
+	}
+	versionedHash := payload[:len(payload)-checksumLen]
+	wantChecksum := payload[len(payload)-checksumLen:]
+	return bytes.Equal(checksum(versionedHash), wantChecksum)
+}
+
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:checksumLen]
+}
+
+// pubKeyHash is re-exported from core.PubKeyHash: core's verification
+// logic needs the same ripemd160(sha256(pubkey)) scheme addresses are
+// built from, so it owns the implementation and wallet just calls it.
+var pubKeyHash = core.PubKeyHash
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+)
+
+// base58Alphabet is the Bitcoin base58 alphabet: it drops 0, O, I, and l,
+// which are easy to mistake for one another in a lot of fonts.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ErrInvalidBase58 is returned by Base58Decode when the input contains a
+// character outside base58Alphabet.
+var ErrInvalidBase58 = errors.New("wallet: invalid base58 string")
+
+// Base58Encode encodes input the same way Bitcoin addresses do.
+func Base58Encode(input []byte) string {
+	x := new(big.Int).SetBytes(input)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for x.Sign() != 0 {
+		x.DivMod(x, base, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	// Leading zero bytes carry no weight in the big.Int above, so they'd
+	// otherwise vanish; re-add them as leading '1's, base58's zero digit.
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	reverseBytes(encoded)
+	return string(encoded)
+}
+
+// Base58Decode reverses Base58Encode.
+func Base58Decode(input string) ([]byte, error) {
+	base := big.NewInt(58)
+	x := big.NewInt(0)
+	for _, c := range input {
+		index := indexOfBase58(byte(c))
+		if index < 0 {
+			return nil, ErrInvalidBase58
+		}
+		x.Mul(x, base)
+		x.Add(x, big.NewInt(int64(index)))
+	}
+
+	decoded := x.Bytes()
+	leadingOnes := 0
+	for _, c := range input {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		leadingOnes++
+	}
+	return append(make([]byte, leadingOnes), decoded...), nil
+}
+
+func indexOfBase58(b byte) int {
+	for i := 0; i < len(base58Alphabet); i++ {
+		if base58Alphabet[i] == b {
+			return i
+		}
+	}
+	return -1
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
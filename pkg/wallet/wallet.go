@@ -0,0 +1,58 @@
+// Package wallet wraps ecdsa keypairs into human-readable, checksummed
+// addresses and persists a named collection of them to disk, following
+// the wallet/address scheme popularized by Bitcoin (and reused widely in
+// blockchain-poc style projects): version byte + ripemd160(sha256(pubkey))
+// + checksum, base58-encoded.
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+)
+
+// Wallet is a keypair and the address derived from it.
+type Wallet struct {
+	PrivateKey *ecdsa.PrivateKey
+	PublicKey  *ecdsa.PublicKey
+}
+
+// NewWallet generates a fresh P256 keypair.
+func NewWallet() (*Wallet, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{PrivateKey: priv, PublicKey: &priv.PublicKey}, nil
+}
+
+// PubKeyHash returns ripemd160(sha256(pubkey)) for the wallet's key, the
+// value stored in a TransactionBody's NextOwner when paying this wallet.
+func (w *Wallet) PubKeyHash() []byte {
+	return pubKeyHash(w.PublicKey)
+}
+
+// Address returns the wallet's base58check-encoded address.
+func (w *Wallet) Address() string {
+	return AddressFromPubKeyHash(w.PubKeyHash())
+}
+
+// GobEncode and GobDecode serialize just the private scalar: the curve is
+// always P256, and the public key and curve are both recoverable from it,
+// so there's no need to round-trip elliptic.Curve (whose concrete type
+// isn't gob-friendly).
+func (w *Wallet) GobEncode() ([]byte, error) {
+	return w.PrivateKey.D.Bytes(), nil
+}
+
+func (w *Wallet) GobDecode(data []byte) error {
+	curve := elliptic.P256()
+	priv := new(ecdsa.PrivateKey)
+	priv.D = new(big.Int).SetBytes(data)
+	priv.PublicKey.Curve = curve
+	priv.PublicKey.X, priv.PublicKey.Y = curve.ScalarBaseMult(data)
+	w.PrivateKey = priv
+	w.PublicKey = &priv.PublicKey
+	return nil
+}
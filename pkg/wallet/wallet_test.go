@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddressRoundTrip(t *testing.T) {
+	w, err := NewWallet()
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+	address := w.Address()
+
+	if !ValidateAddress(address) {
+		t.Fatalf("ValidateAddress(%q) = false, want true", address)
+	}
+	got, err := PubKeyHashFromAddress(address)
+	if err != nil {
+		t.Fatalf("PubKeyHashFromAddress: %v", err)
+	}
+	want := w.PubKeyHash()
+	if string(got) != string(want) {
+		t.Fatalf("PubKeyHashFromAddress(%q) = %x, want %x", address, got, want)
+	}
+}
+
+func TestValidateAddressRejectsCorruption(t *testing.T) {
+	w, err := NewWallet()
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+	address := w.Address()
+	corrupted := []byte(address)
+	corrupted[0]++
+	if ValidateAddress(string(corrupted)) {
+		t.Fatalf("ValidateAddress(%q) = true for a corrupted address, want false", corrupted)
+	}
+}
+
+func TestBase58EncodeDecodeRoundTrip(t *testing.T) {
+	input := []byte{0x00, 0x01, 0x02, 0xff, 0xee, 0x00}
+	encoded := Base58Encode(input)
+	decoded, err := Base58Decode(encoded)
+	if err != nil {
+		t.Fatalf("Base58Decode: %v", err)
+	}
+	if string(decoded) != string(input) {
+		t.Fatalf("Base58Decode(Base58Encode(%x)) = %x, want %x", input, decoded, input)
+	}
+}
+
+func TestWalletsPersistAcrossReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+
+	wallets, err := NewWallets(path)
+	if err != nil {
+		t.Fatalf("NewWallets: %v", err)
+	}
+	address, err := wallets.CreateWallet()
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("wallets file not written: %v", err)
+	}
+
+	reloaded, err := NewWallets(path)
+	if err != nil {
+		t.Fatalf("NewWallets (reload): %v", err)
+	}
+	w, ok := reloaded.GetWallet(address)
+	if !ok {
+		t.Fatalf("GetWallet(%q) not found after reload", address)
+	}
+	if w.Address() != address {
+		t.Fatalf("reloaded wallet address = %q, want %q", w.Address(), address)
+	}
+}
@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+)
+
+// Wallets is a collection of wallets keyed by address, persisted to a
+// gob-encoded file.
+type Wallets struct {
+	path    string
+	Wallets map[string]*Wallet
+}
+
+// NewWallets opens the wallet collection at path, loading any wallets
+// already saved there. A path that doesn't exist yet yields an empty
+// collection.
+func NewWallets(path string) (*Wallets, error) {
+	wallets := &Wallets{path: path, Wallets: make(map[string]*Wallet)}
+	if err := wallets.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// CreateWallet generates a new wallet, saves the collection to disk, and
+// returns the new wallet's address.
+func (ws *Wallets) CreateWallet() (string, error) {
+	w, err := NewWallet()
+	if err != nil {
+		return "", err
+	}
+	address := w.Address()
+	ws.Wallets[address] = w
+	if err := ws.save(); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// GetAddresses returns every address in the collection, in no particular
+// order.
+func (ws *Wallets) GetAddresses() []string {
+	addresses := make([]string, 0, len(ws.Wallets))
+	for address := range ws.Wallets {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// GetWallet looks up the wallet for address.
+func (ws *Wallets) GetWallet(address string) (*Wallet, bool) {
+	w, ok := ws.Wallets[address]
+	return w, ok
+}
+
+func (ws *Wallets) load() error {
+	data, err := os.ReadFile(ws.path)
+	if err != nil {
+		return err
+	}
+	var wallets map[string]*Wallet
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wallets); err != nil {
+		return fmt.Errorf("wallet: decode %s: %w", ws.path, err)
+	}
+	ws.Wallets = wallets
+	return nil
+}
+
+func (ws *Wallets) save() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ws.Wallets); err != nil {
+		return fmt.Errorf("wallet: encode: %w", err)
+	}
+	return os.WriteFile(ws.path, buf.Bytes(), 0600)
+}